@@ -17,7 +17,12 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/viper"
@@ -38,14 +43,141 @@ const (
 	DefaultInitialBatch        = "schema"
 	DefaultNeo4jDatabase       = "neo4j"
 	DefaultCypherShellFormat   = "auto"
+	DefaultConnectionURI       = "bolt://127.0.0.1:7687"
+	DefaultConnectionRouting   = "auto"
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = time.Second
+	DefaultRetryMaxBackoff     = 30 * time.Second
+	DefaultTenantsParallelism  = 4
+	DefaultJobHistory          = 50
+	DefaultLogBuffer           = 1000
+	DefaultReadTimeout         = 30 * time.Second
+	DefaultIdleTimeout         = 2 * time.Minute
+	DefaultTracingServiceName  = "neo4j-graph-tool"
+	DefaultWebhookMaxAttempts  = 5
+	DefaultWebhookTimeout      = 10 * time.Second
+	DefaultRestartPolicy       = RestartOnFailure
+	DefaultMaxRestarts         = 5
+	DefaultRestartBackoff      = time.Second
+	DefaultRestartMaxBackoff   = time.Minute
+	DefaultRestartStableAfter  = 10 * time.Minute
+	DefaultProcessBackend      = BackendDocker
+	DefaultNeo4jBinary         = "neo4j"
+	DefaultInitialDataDir      = "/initial-data"
+	DefaultReadinessInterval   = 2 * time.Second
+	DefaultReadinessTimeout    = 5 * time.Minute
+	DefaultBackupDir           = "/backups"
+	DefaultNeo4jAdmin          = "neo4j-admin"
+	DefaultMigrationTimeout    = time.Minute
+	DefaultKillTimeout         = 30 * time.Second
+	DefaultWipeStrategy        = WipeFile
+	DefaultWipeBatchSize       = 10000
+)
+
+// Backends, which run Neo4j process for supervisor.
+const (
+	// BackendDocker runs entrypoint of the official Neo4j Docker image.
+	BackendDocker = "docker"
+	// BackendBinary runs 'neo4j console' with configured binary.
+	BackendBinary = "binary"
+	// BackendExternal does not run anything, Neo4j is managed outside and supervisor only migrates it.
+	BackendExternal = "external"
+)
+
+// Wipe strategies used to wipe out the database before clean migration.
+const (
+	// WipeFile runs Planner.DropCypherFile from the import dir.
+	WipeFile = "file"
+	// WipeDelete deletes all nodes in batches and drops all constraints and indexes.
+	WipeDelete = "delete"
+	// WipeReplace replaces the database with an empty one, which is supported only by Enterprise edition.
+	WipeReplace = "replace"
+)
+
+// Restart policies of Neo4j process, which exited without being stopped.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// Roles of supervisor HTTP server credentials. Admin can access all routes, read only those, which change nothing.
+const (
+	RoleRead  = "read"
+	RoleAdmin = "admin"
+)
+
+// Events sent to webhooks.
+const (
+	EventNeo4jStarted      = "neo4j.started"
+	EventNeo4jStopped      = "neo4j.stopped"
+	EventNeo4jFailed       = "neo4j.failed"
+	EventMigrationStarted  = "migration.started"
+	EventMigrationFinished = "migration.finished"
+	EventMigrationFailed   = "migration.failed"
 )
 
 type (
 	Config struct {
 		Supervisor *Supervisor `mapstructure:"supervisor"`
 		Planner    *Planner    `mapstructure:"planner"`
+		Connection *Connection `mapstructure:"connection"`
+		Tenants    *Tenants    `mapstructure:"tenants"`
+		Tracing    *Tracing    `mapstructure:"tracing"`
 	}
 
+	// Tracing enables OpenTelemetry tracing exported with OTLP over HTTP, when Endpoint is set.
+	// Endpoint is either 'host:port', or URL with 'http' or 'https' scheme. Standard OTEL_* environment variables,
+	// like OTEL_EXPORTER_OTLP_HEADERS or OTEL_TRACES_SAMPLER, are applied as well.
+	Tracing struct {
+		Endpoint    string `mapstructure:"endpoint"`
+		Insecure    bool   `mapstructure:"insecure"`
+		ServiceName string `mapstructure:"service_name"`
+	}
+
+	// Tenants defines databases, where the same migrations are executed. Databases are collected from static list,
+	// from File with one database per line and from Query executed against 'system' database, which must return
+	// database names in the first column. Parallelism limits how many databases are migrated at once.
+	Tenants struct {
+		Databases   []string `mapstructure:"databases"`
+		File        string   `mapstructure:"file"`
+		Query       string   `mapstructure:"query"`
+		Parallelism int      `mapstructure:"parallelism"`
+	}
+
+	// Connection defines how to connect to Neo4j, both for the driver and for cypher-shell.
+	// Routing 'direct' forces bolt scheme and 'routed' forces neo4j scheme, 'auto' keeps the URI as it is.
+	// TLSCAFile is PEM file with CA certificates, which can be used only with '+s' schemes.
+	// When Username and Database are not set, values from Supervisor are used.
+	// CreateDatabase creates missing databases before migrations, which is supported only by Enterprise edition.
+	Connection struct {
+		URI            string `mapstructure:"uri"`
+		Username       string `mapstructure:"username"`
+		Password       string `mapstructure:"password"`
+		Database       string `mapstructure:"database"`
+		TLSCAFile      string `mapstructure:"tls_ca_file"`
+		Routing        string `mapstructure:"routing"`
+		CreateDatabase bool   `mapstructure:"create_database"`
+	}
+
+	// Supervisor configures supervisor and its HTTP server. LegacyRoutes enables old GET routes,
+	// which change the state, like '/refresh-data'. They are enabled by default, but deprecated,
+	// use '/api/v1' routes instead.
+	// JobHistory is how many finished migration jobs are kept in memory.
+	// LogBuffer is how many last log lines are kept for clients of the log stream.
+	// Zero JobHistory or LogBuffer keeps none.
+	// Auth enables authentication of the HTTP server, when any credentials are set.
+	// HTTP server listens on BindAddress and Port, empty BindAddress means all interfaces.
+	// TLS is enabled, when both TLSCertFile and TLSKeyFile are set. Zero timeout means no timeout.
+	// WriteTimeout is not applied to log stream, but limits synchronous legacy routes.
+	// ReadyWhenMigrated makes '/readyz' fail, until all migrations of InitialBatch up to DefaultGraphVersion
+	// are applied.
+	// Webhooks receive lifecycle and migration events.
+	// Restart defines what happens, when Neo4j process exits unexpectedly.
+	// Process selects how Neo4j is run. InitialDataDir is where relative Planner.BaseFolder is resolved.
+	// Readiness defines how supervisor waits until started Neo4j is ready.
+	// Backup configures dumps of the database made by neo4j-admin.
+	// Shutdown defines how long supervisor waits for running migration and Neo4j on exit.
 	Supervisor struct {
 		LogLevel            string `mapstructure:"log_level"`
 		DefaultGraphVersion string `mapstructure:"default_graph_version"`
@@ -53,6 +185,105 @@ type (
 		Neo4jAuth           string `mapstructure:"neo4j_auth"`
 		Neo4jDatabase       string `mapstructure:"neo4j_database"`
 		Port                int    `mapstructure:"port"`
+		LegacyRoutes        bool   `mapstructure:"legacy_routes"`
+		JobHistory          int    `mapstructure:"job_history"`
+		LogBuffer           int    `mapstructure:"log_buffer"`
+
+		BindAddress  string        `mapstructure:"bind_address"`
+		TLSCertFile  string        `mapstructure:"tls_cert_file"`
+		TLSKeyFile   string        `mapstructure:"tls_key_file"`
+		ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+		WriteTimeout time.Duration `mapstructure:"write_timeout"`
+		IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+
+		ReadyWhenMigrated bool   `mapstructure:"ready_when_migrated"`
+		InitialDataDir    string `mapstructure:"initial_data_dir"`
+
+		Auth     *SupervisorAuth `mapstructure:"auth"`
+		Webhooks []*Webhook      `mapstructure:"webhooks"`
+		Restart  *RestartPolicy  `mapstructure:"restart"`
+		Process  *Process        `mapstructure:"process"`
+
+		Readiness *Readiness `mapstructure:"readiness"`
+		Backup    *Backup    `mapstructure:"backup"`
+		Shutdown  *Shutdown  `mapstructure:"shutdown"`
+	}
+
+	// Shutdown waits up to MigrationTimeout for the running migration step, the rest of the migration
+	// is interrupted. Then Neo4j gets SIGTERM and is killed, when it does not exit within KillTimeout.
+	// Zero timeout waits forever.
+	Shutdown struct {
+		MigrationTimeout time.Duration `mapstructure:"migration_timeout"`
+		KillTimeout      time.Duration `mapstructure:"kill_timeout"`
+	}
+
+	// Backup stores every dump in own sub-directory of Dir. Database is dumped database, the connection one
+	// when empty. Neo4jAdmin is path to 'neo4j-admin', it is looked up in PATH without slash.
+	// BeforeClean makes automatic backup before every clean migration. Keep limits number of automatic backups,
+	// the oldest ones are deleted. Zero Keep keeps all of them.
+	Backup struct {
+		Dir         string `mapstructure:"dir"`
+		Neo4jAdmin  string `mapstructure:"neo4j_admin"`
+		Database    string `mapstructure:"database"`
+		BeforeClean bool   `mapstructure:"before_clean"`
+		Keep        int    `mapstructure:"keep"`
+	}
+
+	// Readiness checks Bolt connectivity every Interval, until Timeout is reached. Zero Timeout waits forever.
+	// When Query is set, it must also return at least one row in Database, or in the default one when empty.
+	Readiness struct {
+		Interval time.Duration `mapstructure:"interval"`
+		Timeout  time.Duration `mapstructure:"timeout"`
+		Query    string        `mapstructure:"query"`
+		Database string        `mapstructure:"database"`
+	}
+
+	// Process selects Backend running Neo4j, one of 'docker', 'binary' or 'external'.
+	// Neo4jBinary is path to 'neo4j' script used by 'binary' backend, it is looked up in PATH without slash.
+	Process struct {
+		Backend     string `mapstructure:"backend"`
+		Neo4jBinary string `mapstructure:"neo4j_binary"`
+	}
+
+	// RestartPolicy defines if crashed Neo4j is started again. Policy 'on-failure' restarts only after
+	// non-zero exit code, 'always' after any exit. Restarts are delayed with exponential backoff
+	// from InitialBackoff up to MaxBackoff. MaxRestarts limits consecutive restarts, zero means no limit.
+	// The counter is reset, when Neo4j keeps running at least for StableAfter.
+	RestartPolicy struct {
+		Policy         string        `mapstructure:"policy"`
+		MaxRestarts    int           `mapstructure:"max_restarts"`
+		InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+		MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+		StableAfter    time.Duration `mapstructure:"stable_after"`
+	}
+
+	// Webhook receives events as JSON POST requests. Events selects which events are sent, all when empty.
+	// When Secret is set, the body is signed with HMAC-SHA256. Failed deliveries are retried up to MaxAttempts
+	// with exponential backoff, Timeout limits every attempt.
+	Webhook struct {
+		URL         string        `mapstructure:"url"`
+		Secret      string        `mapstructure:"secret"`
+		Events      []string      `mapstructure:"events"`
+		MaxAttempts int           `mapstructure:"max_attempts"`
+		Timeout     time.Duration `mapstructure:"timeout"`
+	}
+
+	// SupervisorAuth defines bearer Tokens and Users for HTTP basic authentication.
+	// Every credential has its role, either 'read' or 'admin'.
+	SupervisorAuth struct {
+		Tokens []*AuthToken `mapstructure:"tokens"`
+		Users  []*AuthUser  `mapstructure:"users"`
+	}
+
+	AuthToken struct {
+		Token string `mapstructure:"token"`
+		Role  string `mapstructure:"role"`
+	}
+
+	AuthUser struct {
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password"`
+		Role     string `mapstructure:"role"`
 	}
 
 	Planner struct {
@@ -64,30 +295,81 @@ type (
 		BaseFolder        string `mapstructure:"base_folder"`
 		DropCypherFile    string `mapstructure:"drop_cypher_file"`
 		CypherShellFormat string `mapstructure:"cypher_shell_format"`
+		ReportFile        string `mapstructure:"report_file"`
+
+		// ProcessPerFile runs every migration file in its own cypher-shell process, so the execution report
+		// has timing of each file and failed file can be retried on its own. Otherwise, consecutive Cypher files
+		// share one process, as every process starts new JVM.
+		ProcessPerFile bool `mapstructure:"process_per_file"`
+
+		WipeStrategy  string `mapstructure:"wipe_strategy"`
+		WipeBatchSize int    `mapstructure:"wipe_batch_size"`
+
+		// Protected refuses clean runs and rollbacks of all batches, unless ConfirmToken is supplied.
+		// Use BatchDetail.Protected to protect only some batches.
+		Protected    bool   `mapstructure:"protected"`
+		ConfirmToken string `mapstructure:"confirm_token"`
+
+		Retry *RetryPolicy `mapstructure:"retry"`
 	}
 
+	// RetryPolicy defines how execution steps failed with transient Neo4j error are retried.
+	// Step is retried only when its output contains one of RetryableCodes. Codes are matched as prefixes,
+	// so 'Neo.TransientError' matches all transient errors.
+	RetryPolicy struct {
+		MaxAttempts    int           `mapstructure:"max_attempts"`
+		InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+		MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+		RetryableCodes []string      `mapstructure:"retryable_codes"`
+	}
+
+	// SchemaFolder and FolderDetail can specify Database, where migrations and bookkeeping nodes are stored.
+	// If empty, database from Connection is used.
 	SchemaFolder struct {
 		FolderName    string   `mapstructure:"folder_name"`
 		MigrationType string   `mapstructure:"migration_type"`
 		NodeLabels    []string `mapstructure:"node_labels"`
+		Database      string   `mapstructure:"database"`
 	}
 
 	FolderDetail struct {
 		MigrationType string   `mapstructure:"migration_type"`
 		NodeLabels    []string `mapstructure:"node_labels"`
+		Database      string   `mapstructure:"database"`
 	}
 
+	// BatchDetail can specify Database for all its folders, which do not have own Database.
+	// Protected batch refuses clean runs and rollbacks, see Planner.Protected.
 	BatchDetail struct {
-		Folders []string `mapstructure:"folders"`
+		Folders   []string `mapstructure:"folders"`
+		Database  string   `mapstructure:"database"`
+		Protected bool     `mapstructure:"protected"`
 	}
 )
 
 var (
 	labelCaser = cases.Title(language.English)
+	// Neo4j database names start with a letter and contain only ASCII letters, numbers, dots and dashes.
+	// Underscore is accepted too, as it was allowed in older versions.
+	databaseNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._\-]{2,62}$`)
 
 	logLevelValues          = []string{"fatal", "error", "warn", "warning", "info", "debug", "trace"}
 	migrationTypes          = []string{"change", "up_down"}
 	cypherShellFormatValues = []string{"auto", "verbose", "plain"}
+	routingValues           = []string{"auto", "direct", "routed"}
+	roleValues              = []string{RoleRead, RoleAdmin}
+	backendValues           = []string{BackendDocker, BackendBinary, BackendExternal}
+	restartPolicyValues     = []string{RestartNever, RestartOnFailure, RestartAlways}
+	wipeStrategyValues      = []string{WipeFile, WipeDelete, WipeReplace}
+	eventValues             = []string{
+		EventNeo4jStarted, EventNeo4jStopped, EventNeo4jFailed,
+		EventMigrationStarted, EventMigrationFinished, EventMigrationFailed,
+	}
+	uriSchemes = []string{"bolt", "bolt+s", "bolt+ssc", "neo4j", "neo4j+s", "neo4j+ssc"}
+
+	// DefaultRetryableCodes are Neo4j error codes, which are retried when not configured otherwise.
+	// NotALeader happens during leader switch in the cluster.
+	DefaultRetryableCodes = []string{"Neo.TransientError", "Neo.ClientError.Cluster.NotALeader"}
 )
 
 // New creates a new config containing values from environment variables and default values.
@@ -113,11 +395,62 @@ func LoadFile(fileName string) (*Config, error) {
 	v.SetDefault("supervisor.log_level", DefaultLogLevel)
 	v.SetDefault("supervisor.initial_batch", DefaultInitialBatch)
 	v.SetDefault("supervisor.neo4j_database", DefaultNeo4jDatabase)
+	v.SetDefault("supervisor.legacy_routes", true)
+	v.SetDefault("supervisor.job_history", DefaultJobHistory)
+	v.SetDefault("supervisor.log_buffer", DefaultLogBuffer)
+	v.SetDefault("supervisor.bind_address", "")
+	v.SetDefault("supervisor.tls_cert_file", "")
+	v.SetDefault("supervisor.tls_key_file", "")
+	v.SetDefault("supervisor.read_timeout", DefaultReadTimeout)
+	v.SetDefault("supervisor.write_timeout", 0)
+	v.SetDefault("supervisor.idle_timeout", DefaultIdleTimeout)
+	v.SetDefault("supervisor.ready_when_migrated", false)
+	v.SetDefault("supervisor.initial_data_dir", DefaultInitialDataDir)
+	v.SetDefault("supervisor.process.backend", DefaultProcessBackend)
+	v.SetDefault("supervisor.process.neo4j_binary", DefaultNeo4jBinary)
+	v.SetDefault("supervisor.readiness.interval", DefaultReadinessInterval)
+	v.SetDefault("supervisor.readiness.timeout", DefaultReadinessTimeout)
+	v.SetDefault("supervisor.readiness.query", "")
+	v.SetDefault("supervisor.readiness.database", "")
+	v.SetDefault("supervisor.backup.dir", DefaultBackupDir)
+	v.SetDefault("supervisor.backup.neo4j_admin", DefaultNeo4jAdmin)
+	v.SetDefault("supervisor.backup.database", "")
+	v.SetDefault("supervisor.backup.before_clean", false)
+	v.SetDefault("supervisor.backup.keep", 0)
+	v.SetDefault("supervisor.shutdown.migration_timeout", DefaultMigrationTimeout)
+	v.SetDefault("supervisor.shutdown.kill_timeout", DefaultKillTimeout)
+	v.SetDefault("supervisor.restart.policy", DefaultRestartPolicy)
+	v.SetDefault("supervisor.restart.max_restarts", DefaultMaxRestarts)
+	v.SetDefault("supervisor.restart.initial_backoff", DefaultRestartBackoff)
+	v.SetDefault("supervisor.restart.max_backoff", DefaultRestartMaxBackoff)
+	v.SetDefault("supervisor.restart.stable_after", DefaultRestartStableAfter)
 	v.SetDefault("planner.drop_cypher_file", DefaultDropCypherFile)
 	v.SetDefault("planner.base_folder", DefaultBaseFolder)
 	v.SetDefault("planner.schema_folder.folder_name", DefaultSchemaFolderName)
 	v.SetDefault("planner.schema_folder.migration_type", DefaultSchemaMigrationType)
 	v.SetDefault("planner.cypher_shell_format", DefaultCypherShellFormat)
+	v.SetDefault("planner.process_per_file", false)
+	v.SetDefault("planner.wipe_strategy", DefaultWipeStrategy)
+	v.SetDefault("planner.wipe_batch_size", DefaultWipeBatchSize)
+	v.SetDefault("planner.protected", false)
+	v.SetDefault("planner.confirm_token", "")
+	v.SetDefault("connection.uri", DefaultConnectionURI)
+	v.SetDefault("connection.routing", DefaultConnectionRouting)
+	// Empty defaults make viper aware of keys, so they can be set with environment variables.
+	for _, key := range []string{"username", "password", "database", "tls_ca_file"} {
+		v.SetDefault("connection."+key, "")
+	}
+	v.SetDefault("connection.create_database", false)
+	v.SetDefault("planner.retry.max_attempts", DefaultRetryMaxAttempts)
+	v.SetDefault("planner.retry.initial_backoff", DefaultRetryInitialBackoff)
+	v.SetDefault("planner.retry.max_backoff", DefaultRetryMaxBackoff)
+	v.SetDefault("planner.retry.retryable_codes", DefaultRetryableCodes)
+	v.SetDefault("tenants.file", "")
+	v.SetDefault("tenants.query", "")
+	v.SetDefault("tenants.parallelism", DefaultTenantsParallelism)
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.insecure", false)
+	v.SetDefault("tracing.service_name", DefaultTracingServiceName)
 
 	v.AutomaticEnv()
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -186,7 +519,118 @@ func (c *Config) validateValues() error {
 	if err := c.validatePlanner(); err != nil {
 		return err
 	}
-	return c.validateFoldersAndBatches()
+	if err := c.validateConnection(); err != nil {
+		return err
+	}
+	if err := c.validateFoldersAndBatches(); err != nil {
+		return err
+	}
+	if err := c.validateTracing(); err != nil {
+		return err
+	}
+	return c.validateTenants()
+}
+
+func (c *Config) validateTracing() error {
+	if !c.Tracing.Enabled() {
+		return nil
+	}
+	endpoint := c.Tracing.Endpoint
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("tracing endpoint '%s' must be host:port or URL with http or https scheme", endpoint)
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return fmt.Errorf("tracing endpoint '%s' must be host:port or URL with http or https scheme", endpoint)
+	}
+	return nil
+}
+
+// Enabled returns true when tracing endpoint is set. Can be called on nil.
+func (t *Tracing) Enabled() bool {
+	return t != nil && t.Endpoint != ""
+}
+
+func (c *Config) validateTenants() error {
+	// Tenants are optional, Normalize sets the default parallelism.
+	t := c.Tenants
+	if t == nil {
+		return nil
+	}
+	if t.Parallelism < 1 {
+		return errors.New("tenants parallelism must be at least 1")
+	}
+	if db, ok := duplicateElements(t.Databases); ok {
+		return fmt.Errorf("duplicate tenant database '%s'", db)
+	}
+	for _, db := range t.Databases {
+		if db == "" {
+			return errors.New("tenant database name cannot be empty")
+		}
+		if err := validateDatabaseName(db); err != nil {
+			return fmt.Errorf("in tenants %w", err)
+		}
+	}
+	if !t.Configured() {
+		return nil
+	}
+
+	// Every tenant gets all folders, so they cannot be pinned to another database.
+	if c.Planner.SchemaFolder.Database != "" {
+		return errors.New("tenants cannot be used together with database of schema folder")
+	}
+	for folderName, folderDetail := range c.Planner.Folders {
+		if folderDetail.Database != "" {
+			return fmt.Errorf("tenants cannot be used together with database of folder '%s'", folderName)
+		}
+	}
+	for batchName, batchDetail := range c.Planner.Batches {
+		if batchDetail.Database != "" {
+			return fmt.Errorf("tenants cannot be used together with database of batch '%s'", batchName)
+		}
+	}
+	return nil
+}
+
+// Configured returns true, when at least one source of tenant databases is set.
+func (t *Tenants) Configured() bool {
+	return t != nil && (len(t.Databases) > 0 || t.File != "" || t.Query != "")
+}
+
+func (c *Config) validateConnection() error {
+	// Connection is optional, Normalize sets the default one.
+	if c.Connection == nil {
+		return nil
+	}
+
+	scheme, _, found := strings.Cut(c.Connection.URI, "://")
+	if !found || !stringInArray(uriSchemes, scheme) {
+		return fmt.Errorf("connection uri '%s' is invalid, scheme must be one of '%s'",
+			c.Connection.URI, strings.Join(uriSchemes, ","))
+	}
+	if c.Connection.Routing != "" && !stringInArray(routingValues, c.Connection.Routing) {
+		return fmt.Errorf("connection routing value '%s' is invalid, must be one of '%s'",
+			c.Connection.Routing, strings.Join(routingValues, ","))
+	}
+	switch {
+	case c.Connection.TLSCAFile == "" || strings.HasSuffix(scheme, "+s"):
+	case strings.HasSuffix(scheme, "+ssc"):
+		// Driver always skips verification with '+ssc', so the CA would be silently ignored.
+		return fmt.Errorf("connection tls_ca_file cannot be used with '%s' scheme, which skips CA verification, "+
+			"use 'bolt+s' or 'neo4j+s' with custom CA", scheme)
+	default:
+		return errors.New("connection tls_ca_file can be used only with 'bolt+s' or 'neo4j+s' scheme")
+	}
+	if err := validateDatabaseName(c.Connection.Database); err != nil {
+		return fmt.Errorf("in connection %w", err)
+	}
+	if c.Connection.Username == "" && c.Connection.Password != "" {
+		return errors.New("connection password is set, but username is missing")
+	}
+	return nil
 }
 
 func (c *Config) validateSupervisor() error {
@@ -210,6 +654,26 @@ func (c *Config) validateSupervisor() error {
 		return errors.New("port number must be in range 1024 - 65535")
 	}
 
+	if addr := c.Supervisor.BindAddress; strings.Contains(addr, ":") && net.ParseIP(addr) == nil {
+		return fmt.Errorf("bind_address '%s' must be IP address or host name without port", addr)
+	}
+
+	if (c.Supervisor.TLSCertFile == "") != (c.Supervisor.TLSKeyFile == "") {
+		return errors.New("tls_cert_file and tls_key_file must be set together")
+	}
+
+	if c.Supervisor.ReadTimeout < 0 || c.Supervisor.WriteTimeout < 0 || c.Supervisor.IdleTimeout < 0 {
+		return errors.New("supervisor timeouts cannot be negative")
+	}
+
+	if c.Supervisor.JobHistory < 0 {
+		return errors.New("job_history cannot be negative")
+	}
+
+	if c.Supervisor.LogBuffer < 0 {
+		return errors.New("log_buffer cannot be negative")
+	}
+
 	if c.Supervisor.Neo4jAuth != "" && !strings.Contains(c.Supervisor.Neo4jAuth, "/") {
 		return errors.New("neo4j auth must be in format username/passsword")
 	}
@@ -219,9 +683,153 @@ func (c *Config) validateSupervisor() error {
 			c.Supervisor.LogLevel, strings.Join(logLevelValues, ","))
 	}
 
+	if err := c.validateWebhooks(); err != nil {
+		return err
+	}
+	if err := c.validateRestart(); err != nil {
+		return err
+	}
+	if err := c.validateReadiness(); err != nil {
+		return err
+	}
+	if err := c.validateBackup(); err != nil {
+		return err
+	}
+	// Shutdown is optional, Normalize sets the default one.
+	if sd := c.Supervisor.Shutdown; sd != nil && (sd.MigrationTimeout < 0 || sd.KillTimeout < 0) {
+		return errors.New("shutdown migration_timeout and kill_timeout cannot be negative")
+	}
+	if p := c.Supervisor.Process; p != nil && !stringInArray(backendValues, p.Backend) {
+		return fmt.Errorf("process backend '%s' is invalid, must be one of '%s'",
+			p.Backend, strings.Join(backendValues, ","))
+	}
+	return c.validateAuth()
+}
+
+func (c *Config) validateReadiness() error {
+	// Readiness is optional, Normalize sets the default one.
+	r := c.Supervisor.Readiness
+	if r == nil {
+		return nil
+	}
+	if r.Interval < 0 || r.Timeout < 0 {
+		return errors.New("readiness interval and timeout cannot be negative")
+	}
+	if err := validateDatabaseName(r.Database); err != nil {
+		return fmt.Errorf("in readiness %w", err)
+	}
+	return nil
+}
+
+func (c *Config) validateBackup() error {
+	// Backup is optional, Normalize sets the default one.
+	b := c.Supervisor.Backup
+	if b == nil {
+		return nil
+	}
+	if b.Keep < 0 {
+		return errors.New("backup keep cannot be negative")
+	}
+	if err := validateDatabaseName(b.Database); err != nil {
+		return fmt.Errorf("in backup %w", err)
+	}
+	return nil
+}
+
+func (c *Config) validateRestart() error {
+	// Restart is optional, Normalize sets the default one.
+	r := c.Supervisor.Restart
+	if r == nil {
+		return nil
+	}
+	if !stringInArray(restartPolicyValues, r.Policy) {
+		return fmt.Errorf("restart policy '%s' is invalid, must be one of '%s'",
+			r.Policy, strings.Join(restartPolicyValues, ","))
+	}
+	if r.MaxRestarts < 0 {
+		return errors.New("restart max_restarts cannot be negative")
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 || r.StableAfter < 0 {
+		return errors.New("restart backoff and stable_after cannot be negative")
+	}
+	if r.MaxBackoff < r.InitialBackoff {
+		return errors.New("restart max_backoff cannot be lower than initial_backoff")
+	}
+	return nil
+}
+
+func (c *Config) validateWebhooks() error {
+	for _, hook := range c.Supervisor.Webhooks {
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook url '%s' must be URL with http or https scheme", hook.URL)
+		}
+		for _, event := range hook.Events {
+			if !stringInArray(eventValues, event) {
+				return fmt.Errorf("webhook event '%s' is invalid, must be one of '%s'",
+					event, strings.Join(eventValues, ","))
+			}
+		}
+		if hook.MaxAttempts < 0 {
+			return errors.New("webhook max_attempts cannot be negative")
+		}
+		if hook.Timeout < 0 {
+			return errors.New("webhook timeout cannot be negative")
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateAuth() error {
+	if c.Supervisor.Auth == nil {
+		return nil
+	}
+
+	tokens := map[string]bool{}
+	for _, t := range c.Supervisor.Auth.Tokens {
+		if t.Token == "" {
+			return errors.New("auth token cannot be empty")
+		}
+		if tokens[t.Token] {
+			return errors.New("duplicate auth token")
+		}
+		tokens[t.Token] = true
+		if err := validateRole(t.Role); err != nil {
+			return err
+		}
+	}
+
+	users := map[string]bool{}
+	for _, u := range c.Supervisor.Auth.Users {
+		if u.Username == "" || strings.Contains(u.Username, ":") {
+			return errors.New("auth username cannot be empty or contain ':'")
+		}
+		if users[u.Username] {
+			return fmt.Errorf("duplicate auth user '%s'", u.Username)
+		}
+		users[u.Username] = true
+		if u.Password == "" {
+			return fmt.Errorf("auth password of user '%s' cannot be empty", u.Username)
+		}
+		if err := validateRole(u.Role); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func validateRole(role string) error {
+	if !stringInArray(roleValues, role) {
+		return fmt.Errorf("auth role '%s' is invalid, must be one of '%s'", role, strings.Join(roleValues, ","))
+	}
+	return nil
+}
+
+// Enabled returns true when any credentials are set. Can be called on nil.
+func (a *SupervisorAuth) Enabled() bool {
+	return a != nil && (len(a.Tokens) > 0 || len(a.Users) > 0)
+}
+
 func (c *Config) validatePlanner() error {
 	if c.Planner.BaseFolder == "" {
 		return errors.New("base_folder cannot be empty")
@@ -240,11 +848,22 @@ func (c *Config) validatePlanner() error {
 	if label, ok := duplicateElements(c.Planner.SchemaFolder.NodeLabels); ok {
 		return fmt.Errorf("duplicate label '%s' in schemaFolder", label)
 	}
+	if err := validateDatabaseName(c.Planner.SchemaFolder.Database); err != nil {
+		return fmt.Errorf("in folder schema %w", err)
+	}
 
 	if !stringInArray(cypherShellFormatValues, c.Planner.CypherShellFormat) {
 		return fmt.Errorf("cypher_shell_format value '%s' is invalid, must be one of '%s'",
 			c.Planner.CypherShellFormat, strings.Join(cypherShellFormatValues, ","))
 	}
+	// Empty strategy is allowed, Normalize sets the default one.
+	if c.Planner.WipeStrategy != "" && !stringInArray(wipeStrategyValues, c.Planner.WipeStrategy) {
+		return fmt.Errorf("wipe_strategy value '%s' is invalid, must be one of '%s'",
+			c.Planner.WipeStrategy, strings.Join(wipeStrategyValues, ","))
+	}
+	if c.Planner.WipeBatchSize < 0 {
+		return errors.New("wipe_batch_size cannot be negative")
+	}
 
 	for cmd, path := range c.Planner.AllowedCommands {
 		if cmd == "" {
@@ -255,6 +874,33 @@ func (c *Config) validatePlanner() error {
 		}
 	}
 
+	return c.validateRetry()
+}
+
+func (c *Config) validateRetry() error {
+	// Retry is optional, Normalize sets the default one.
+	if c.Planner.Retry == nil {
+		return nil
+	}
+	return c.Planner.Retry.Validate()
+}
+
+// Validate validates retry policy, which can be also created outside of config, for example from HTTP request.
+func (r *RetryPolicy) Validate() error {
+	if r.MaxAttempts < 1 {
+		return errors.New("retry max_attempts must be at least 1")
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		return errors.New("retry backoff cannot be negative")
+	}
+	if r.MaxBackoff < r.InitialBackoff {
+		return errors.New("retry max_backoff cannot be lower than initial_backoff")
+	}
+	for _, code := range r.RetryableCodes {
+		if code == "" {
+			return errors.New("retryable code cannot be empty")
+		}
+	}
 	return nil
 }
 
@@ -284,9 +930,16 @@ func (c *Config) validateFoldersAndBatches() error {
 		if label, ok := duplicateElements(folderDetail.NodeLabels); ok {
 			return fmt.Errorf("duplicate label '%s' in folder named '%s'", label, folderName)
 		}
+		if err := validateDatabaseName(folderDetail.Database); err != nil {
+			return fmt.Errorf("in folder '%s' %w", folderName, err)
+		}
 		possibleFolders[folderName] = true
 	}
 
+	// Folder without own database takes it from the batch, so all its batches must agree.
+	batchDatabases := map[string]string{}
+	protected := c.Planner.Protected
+
 	for batchName, batchDetail := range c.Planner.Batches {
 		if batchName == "" {
 			return errors.New("name of batch in Planner.Batches can't be an empty string")
@@ -304,9 +957,31 @@ func (c *Config) validateFoldersAndBatches() error {
 			if _, isDefined := possibleFolders[folder]; !isDefined {
 				return fmt.Errorf("folder '%s' in batch '%s' is not defined in planner.folders", folder, batchName)
 			}
+			if batchDetail.Database == "" || c.Planner.Folders[folder].Database != "" {
+				continue
+			}
+			if db, ok := batchDatabases[folder]; ok && db != batchDetail.Database {
+				return fmt.Errorf("folder '%s' is in batches with different databases '%s' and '%s'",
+					folder, db, batchDetail.Database)
+			}
+			batchDatabases[folder] = batchDetail.Database
+		}
+		if err := validateDatabaseName(batchDetail.Database); err != nil {
+			return fmt.Errorf("in batch '%s' %w", batchName, err)
 		}
+		protected = protected || batchDetail.Protected
+	}
+
+	if protected && c.Planner.ConfirmToken == "" {
+		return errors.New("confirm_token cannot be empty, when any batch is protected")
 	}
+	return nil
+}
 
+func validateDatabaseName(name string) error {
+	if name != "" && !databaseNamePattern.MatchString(name) {
+		return fmt.Errorf("database name '%s' is invalid", name)
+	}
 	return nil
 }
 
@@ -358,15 +1033,137 @@ func (c *Config) Normalize() error {
 	if c.Planner.CypherShellFormat == "" {
 		c.Planner.CypherShellFormat = DefaultCypherShellFormat
 	}
+	if c.Planner.WipeStrategy == "" {
+		c.Planner.WipeStrategy = DefaultWipeStrategy
+	}
+	if c.Planner.WipeBatchSize == 0 {
+		c.Planner.WipeBatchSize = DefaultWipeBatchSize
+	}
+
+	if c.Planner.Retry == nil {
+		c.Planner.Retry = &RetryPolicy{}
+	}
+	if c.Planner.Retry.MaxAttempts == 0 {
+		c.Planner.Retry.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if c.Planner.Retry.InitialBackoff == 0 {
+		c.Planner.Retry.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if c.Planner.Retry.MaxBackoff == 0 {
+		c.Planner.Retry.MaxBackoff = max(DefaultRetryMaxBackoff, c.Planner.Retry.InitialBackoff)
+	}
+	if c.Planner.Retry.RetryableCodes == nil {
+		c.Planner.Retry.RetryableCodes = slices.Clone(DefaultRetryableCodes)
+	}
+
+	c.normalizeConnection()
+
+	if c.Tenants == nil {
+		c.Tenants = &Tenants{}
+	}
+	if c.Tenants.Parallelism == 0 {
+		c.Tenants.Parallelism = DefaultTenantsParallelism
+	}
+
+	if c.Tracing == nil {
+		c.Tracing = &Tracing{}
+	}
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = DefaultTracingServiceName
+	}
 
 	// Supervisor might not be defined
 	if c.Supervisor != nil {
 		c.Supervisor.LogLevel = strings.ToLower(c.Supervisor.LogLevel)
+		c.normalizeRestart()
+		if c.Supervisor.Readiness == nil {
+			c.Supervisor.Readiness = &Readiness{Timeout: DefaultReadinessTimeout}
+		}
+		if c.Supervisor.Readiness.Interval == 0 {
+			c.Supervisor.Readiness.Interval = DefaultReadinessInterval
+		}
+		if c.Supervisor.Shutdown == nil {
+			c.Supervisor.Shutdown = &Shutdown{
+				MigrationTimeout: DefaultMigrationTimeout,
+				KillTimeout:      DefaultKillTimeout,
+			}
+		}
+		if c.Supervisor.Backup == nil {
+			c.Supervisor.Backup = &Backup{}
+		}
+		if c.Supervisor.Backup.Dir == "" {
+			c.Supervisor.Backup.Dir = DefaultBackupDir
+		}
+		if c.Supervisor.Backup.Neo4jAdmin == "" {
+			c.Supervisor.Backup.Neo4jAdmin = DefaultNeo4jAdmin
+		}
+		if c.Supervisor.Process == nil {
+			c.Supervisor.Process = &Process{}
+		}
+		if c.Supervisor.Process.Backend == "" {
+			c.Supervisor.Process.Backend = DefaultProcessBackend
+		}
+		if c.Supervisor.Process.Neo4jBinary == "" {
+			c.Supervisor.Process.Neo4jBinary = DefaultNeo4jBinary
+		}
+		if c.Supervisor.InitialDataDir == "" {
+			c.Supervisor.InitialDataDir = DefaultInitialDataDir
+		}
+		for _, hook := range c.Supervisor.Webhooks {
+			if hook.MaxAttempts == 0 {
+				hook.MaxAttempts = DefaultWebhookMaxAttempts
+			}
+			if hook.Timeout == 0 {
+				hook.Timeout = DefaultWebhookTimeout
+			}
+		}
 	}
 
 	return nil
 }
 
+func (c *Config) normalizeRestart() {
+	if c.Supervisor.Restart == nil {
+		c.Supervisor.Restart = &RestartPolicy{}
+	}
+	r := c.Supervisor.Restart
+	if r.Policy == "" {
+		r.Policy = DefaultRestartPolicy
+	}
+	if r.InitialBackoff == 0 {
+		r.InitialBackoff = DefaultRestartBackoff
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = max(DefaultRestartMaxBackoff, r.InitialBackoff)
+	}
+	if r.StableAfter == 0 {
+		r.StableAfter = DefaultRestartStableAfter
+	}
+}
+
+func (c *Config) normalizeConnection() {
+	if c.Connection == nil {
+		c.Connection = &Connection{}
+	}
+	if c.Connection.URI == "" {
+		c.Connection.URI = DefaultConnectionURI
+	}
+	if c.Connection.Routing == "" {
+		c.Connection.Routing = DefaultConnectionRouting
+	}
+	// Keep backward compatibility with Supervisor settings, which were used before Connection existed.
+	if c.Supervisor != nil {
+		if c.Connection.Username == "" {
+			if user, pass, ok := strings.Cut(c.Supervisor.Neo4jAuth, "/"); ok {
+				c.Connection.Username, c.Connection.Password = user, pass
+			}
+		}
+		if c.Connection.Database == "" {
+			c.Connection.Database = c.Supervisor.Neo4jDatabase
+		}
+	}
+}
+
 func generateLabelName(folderName string) string {
 	return labelCaser.String(folderName) + DefaultNodeSubString
 }
@@ -15,6 +15,8 @@
 package config_test
 
 import (
+	"time"
+
 	"github.com/indykite/neo4j-graph-tool-core/config"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -34,37 +36,142 @@ var _ = Describe("LoadFile", func() {
 				"InitialBatch":        Equal("schema"),
 				"Neo4jAuth":           Equal("username/password"),
 				"Neo4jDatabase":       Equal("my_db"),
+				"LegacyRoutes":        BeFalse(),
+				"JobHistory":          Equal(10),
+				"LogBuffer":           Equal(100),
+				"BindAddress":         Equal("127.0.0.1"),
+				"TLSCertFile":         Equal("server.crt"),
+				"TLSKeyFile":          Equal("server.key"),
+				"ReadTimeout":         Equal(10 * time.Second),
+				"WriteTimeout":        Equal(5 * time.Minute),
+				"IdleTimeout":         Equal(time.Minute),
+				"ReadyWhenMigrated":   BeTrue(),
+				"InitialDataDir":      Equal("/data"),
+				"Process": PointTo(MatchAllFields(Fields{
+					"Backend":     Equal(config.BackendBinary),
+					"Neo4jBinary": Equal("/opt/neo4j/bin/neo4j"),
+				})),
+				"Readiness": PointTo(MatchAllFields(Fields{
+					"Interval": Equal(5 * time.Second),
+					"Timeout":  Equal(30 * time.Minute),
+					"Query":    Equal("SHOW DATABASE neo4j YIELD currentStatus WHERE currentStatus = 'online'"),
+					"Database": Equal("system"),
+				})),
+				"Backup": PointTo(MatchAllFields(Fields{
+					"Dir":         Equal("/var/backups/neo4j"),
+					"Neo4jAdmin":  Equal("/opt/neo4j/bin/neo4j-admin"),
+					"Database":    Equal("my_db"),
+					"BeforeClean": BeTrue(),
+					"Keep":        Equal(3),
+				})),
+				"Shutdown": PointTo(MatchAllFields(Fields{
+					"MigrationTimeout": Equal(5 * time.Minute),
+					"KillTimeout":      Equal(time.Minute),
+				})),
+				"Auth": PointTo(MatchAllFields(Fields{
+					"Tokens": HaveExactElements(PointTo(MatchAllFields(Fields{
+						"Token": Equal("admin-token"),
+						"Role":  Equal(config.RoleAdmin),
+					}))),
+					"Users": HaveExactElements(PointTo(MatchAllFields(Fields{
+						"Username": Equal("viewer"),
+						"Password": Equal("secret"),
+						"Role":     Equal(config.RoleRead),
+					}))),
+				})),
+				"Webhooks": HaveExactElements(
+					PointTo(MatchAllFields(Fields{
+						"URL":         Equal("https://chat.example.com/hooks/graph"),
+						"Secret":      Equal("webhook-secret"),
+						"Events":      HaveExactElements(config.EventMigrationFinished, config.EventMigrationFailed),
+						"MaxAttempts": Equal(config.DefaultWebhookMaxAttempts),
+						"Timeout":     Equal(config.DefaultWebhookTimeout),
+					})),
+					PointTo(MatchAllFields(Fields{
+						"URL":         Equal("http://127.0.0.1:9000/events"),
+						"Secret":      BeEmpty(),
+						"Events":      BeEmpty(),
+						"MaxAttempts": Equal(2),
+						"Timeout":     Equal(3 * time.Second),
+					})),
+				),
+				"Restart": PointTo(MatchAllFields(Fields{
+					"Policy":         Equal(config.RestartAlways),
+					"MaxRestarts":    Equal(3),
+					"InitialBackoff": Equal(2 * time.Second),
+					"MaxBackoff":     Equal(config.DefaultRestartMaxBackoff),
+					"StableAfter":    Equal(config.DefaultRestartStableAfter),
+				})),
+			})),
+			"Connection": PointTo(MatchAllFields(Fields{
+				"URI":            Equal(config.DefaultConnectionURI),
+				"Username":       Equal("username"),
+				"Password":       Equal("password"),
+				"Database":       Equal("my_db"),
+				"TLSCAFile":      BeEmpty(),
+				"Routing":        Equal("auto"),
+				"CreateDatabase": BeTrue(),
+			})),
+			"Tenants": PointTo(MatchAllFields(Fields{
+				"Databases":   BeEmpty(),
+				"File":        BeEmpty(),
+				"Query":       BeEmpty(),
+				"Parallelism": Equal(8),
+			})),
+			"Tracing": PointTo(MatchAllFields(Fields{
+				"Endpoint":    Equal("otel-collector:4318"),
+				"Insecure":    BeTrue(),
+				"ServiceName": Equal(config.DefaultTracingServiceName),
 			})),
 			"Planner": PointTo(MatchAllFields(Fields{
 				"BaseFolder":        Equal("all-data"),
 				"DropCypherFile":    Equal("drop-file.cypher"),
 				"CypherShellFormat": Equal("verbose"),
+				"ReportFile":        Equal("report.json"),
+				"ProcessPerFile":    BeTrue(),
+				"WipeStrategy":      Equal(config.WipeDelete),
+				"WipeBatchSize":     Equal(500),
+				"Protected":         BeFalse(),
+				"ConfirmToken":      Equal("staging"),
+				"Retry": PointTo(MatchAllFields(Fields{
+					"MaxAttempts":    Equal(5),
+					"InitialBackoff": Equal(500 * time.Millisecond),
+					"MaxBackoff":     Equal(10 * time.Second),
+					"RetryableCodes": ConsistOf("Neo.TransientError.Transaction"),
+				})),
 				"AllowedCommands": MatchAllKeys(Keys{
 					"another-tool": Equal("/var/path/to/another-tool"),
 					"graph-tool":   Equal("/app/graph-tool"),
 				}),
 				"Batches": MatchAllKeys(Keys{
 					"data": PointTo(MatchAllFields(Fields{
-						"Folders": ConsistOf("data"),
+						"Folders":   ConsistOf("data"),
+						"Database":  BeEmpty(),
+						"Protected": BeFalse(),
 					})),
 					"performance": PointTo(MatchAllFields(Fields{
-						"Folders": ConsistOf("data", "perf"),
+						"Folders":   ConsistOf("data", "perf"),
+						"Database":  BeEmpty(),
+						"Protected": BeTrue(),
 					})),
 				}),
 				"Folders": MatchAllKeys(Keys{
 					"data": PointTo(MatchAllFields(Fields{
 						"MigrationType": Equal("change"),
 						"NodeLabels":    ConsistOf("DataVersion"),
+						"Database":      BeEmpty(),
 					})),
 					"perf": PointTo(MatchAllFields(Fields{
 						"MigrationType": Equal("change"),
 						"NodeLabels":    ConsistOf("PerfVersion"),
+						"Database":      Equal("perf"),
 					})),
 				}),
 				"SchemaFolder": PointTo(MatchAllFields(Fields{
 					"FolderName":    Equal("base-schema"),
 					"MigrationType": Equal("up_down"),
 					"NodeLabels":    ConsistOf("SchemaVersion"),
+					"Database":      BeEmpty(),
 				})),
 			})),
 		})))
@@ -78,10 +185,34 @@ var _ = Describe("LoadFile", func() {
 			"GT_SUPERVISOR_INITIAL_BATCH":          "data",
 			"GT_SUPERVISOR_NEO4J_AUTH":             "name/pass",
 			"GT_SUPERVISOR_NEO4J_DATABASE":         "another_db",
+			"GT_SUPERVISOR_LEGACY_ROUTES":          "false",
+			"GT_SUPERVISOR_JOB_HISTORY":            "20",
+			"GT_SUPERVISOR_LOG_BUFFER":             "0",
+			"GT_SUPERVISOR_BIND_ADDRESS":           "::1",
+			"GT_SUPERVISOR_TLS_CERT_FILE":          "/etc/tls.crt",
+			"GT_SUPERVISOR_TLS_KEY_FILE":           "/etc/tls.key",
+			"GT_SUPERVISOR_WRITE_TIMEOUT":          "0s",
+			"GT_SUPERVISOR_READY_WHEN_MIGRATED":    "false",
+			"GT_SUPERVISOR_RESTART_POLICY":         "never",
+			"GT_SUPERVISOR_PROCESS_BACKEND":        "external",
+			"GT_SUPERVISOR_INITIAL_DATA_DIR":       "/var/lib/graph",
+			"GT_SUPERVISOR_READINESS_TIMEOUT":      "0s",
+			"GT_SUPERVISOR_BACKUP_DIR":             "/mnt/backups",
+			"GT_SUPERVISOR_BACKUP_BEFORE_CLEAN":    "false",
+			"GT_SUPERVISOR_SHUTDOWN_KILL_TIMEOUT":  "0s",
 			"GT_PLANNER_BASE_FOLDER":               "base-schema",
 			"GT_PLANNER_DROP_CYPHER_FILE":          "cypher.file",
 			"GT_PLANNER_SCHEMA_FOLDER_NODE_LABELS": "abc,def", // array of two elements
 			"GT_PLANNER_CYPHER_SHELL_FORMAT":       "plain",
+			"GT_PLANNER_WIPE_STRATEGY":             "replace",
+			"GT_PLANNER_PROTECTED":                 "true",
+			"GT_CONNECTION_URI":                    "neo4j+s://example.com",
+			"GT_CONNECTION_DATABASE":               "remote_db",
+			"GT_CONNECTION_TLS_CA_FILE":            "/etc/ca.pem",
+			"GT_CONNECTION_CREATE_DATABASE":        "false",
+			"GT_TENANTS_PARALLELISM":               "2",
+			"GT_TRACING_ENDPOINT":                  "https://otel.example.com/v1/traces",
+			"GT_TRACING_SERVICE_NAME":              "graph-tool-dev",
 		})
 		GinkgoT().Cleanup(closer)
 
@@ -95,15 +226,66 @@ var _ = Describe("LoadFile", func() {
 				"InitialBatch":        Equal("data"),
 				"Neo4jAuth":           Equal("name/pass"),
 				"Neo4jDatabase":       Equal("another_db"),
+				"LegacyRoutes":        BeFalse(),
+				"JobHistory":          Equal(20),
+				"LogBuffer":           BeZero(),
+				"BindAddress":         Equal("::1"),
+				"TLSCertFile":         Equal("/etc/tls.crt"),
+				"TLSKeyFile":          Equal("/etc/tls.key"),
+				"ReadTimeout":         Equal(10 * time.Second),
+				"WriteTimeout":        BeZero(),
+				"IdleTimeout":         Equal(time.Minute),
+				"ReadyWhenMigrated":   BeFalse(),
+				"InitialDataDir":      Equal("/var/lib/graph"),
+				"Auth":                Not(BeNil()),
+				"Webhooks":            HaveLen(2),
+				"Process": PointTo(MatchAllFields(Fields{
+					"Backend":     Equal(config.BackendExternal),
+					"Neo4jBinary": Equal("/opt/neo4j/bin/neo4j"),
+				})),
+				"Readiness": PointTo(MatchFields(IgnoreExtras, Fields{
+					"Timeout": BeZero(),
+				})),
+				"Backup": PointTo(MatchFields(IgnoreExtras, Fields{
+					"Dir":         Equal("/mnt/backups"),
+					"BeforeClean": BeFalse(),
+				})),
+				"Shutdown": PointTo(MatchAllFields(Fields{
+					"MigrationTimeout": Equal(5 * time.Minute),
+					"KillTimeout":      BeZero(),
+				})),
+				"Restart": PointTo(MatchFields(IgnoreExtras, Fields{
+					"Policy":      Equal(config.RestartNever),
+					"MaxRestarts": Equal(3),
+				})),
+			})),
+			"Connection": PointTo(MatchFields(IgnoreExtras, Fields{
+				"URI":            Equal("neo4j+s://example.com"),
+				"Username":       Equal("name"),
+				"Database":       Equal("remote_db"),
+				"TLSCAFile":      Equal("/etc/ca.pem"),
+				"CreateDatabase": BeFalse(),
+			})),
+			"Tenants": PointTo(MatchFields(IgnoreExtras, Fields{
+				"Parallelism": Equal(2),
+			})),
+			"Tracing": PointTo(MatchAllFields(Fields{
+				"Endpoint":    Equal("https://otel.example.com/v1/traces"),
+				"Insecure":    BeTrue(),
+				"ServiceName": Equal("graph-tool-dev"),
 			})),
 			"Planner": PointTo(MatchFields(IgnoreExtras, Fields{
 				"BaseFolder":        Equal("base-schema"),
 				"DropCypherFile":    Equal("cypher.file"),
 				"CypherShellFormat": Equal("plain"),
+				"WipeStrategy":      Equal(config.WipeReplace),
+				"Protected":         BeTrue(),
+				"ConfirmToken":      Equal("staging"),
 				"SchemaFolder": PointTo(MatchAllFields(Fields{
 					"FolderName":    Equal("base-schema"),
 					"MigrationType": Equal(config.DefaultSchemaMigrationType),
 					"NodeLabels":    ConsistOf("abc", "def"),
+					"Database":      BeEmpty(),
 				})),
 			})),
 		})))
@@ -139,17 +321,91 @@ var _ = Describe("LoadFile", func() {
 				"InitialBatch":        Equal("schema"),
 				"Neo4jAuth":           HaveLen(0),
 				"Neo4jDatabase":       Equal("neo4j"),
+				"LegacyRoutes":        BeTrue(),
+				"JobHistory":          Equal(config.DefaultJobHistory),
+				"LogBuffer":           Equal(config.DefaultLogBuffer),
+				"BindAddress":         BeEmpty(),
+				"TLSCertFile":         BeEmpty(),
+				"TLSKeyFile":          BeEmpty(),
+				"ReadTimeout":         Equal(config.DefaultReadTimeout),
+				"WriteTimeout":        BeZero(),
+				"IdleTimeout":         Equal(config.DefaultIdleTimeout),
+				"ReadyWhenMigrated":   BeFalse(),
+				"InitialDataDir":      Equal(config.DefaultInitialDataDir),
+				"Auth":                BeNil(),
+				"Webhooks":            BeEmpty(),
+				"Process": PointTo(MatchAllFields(Fields{
+					"Backend":     Equal(config.DefaultProcessBackend),
+					"Neo4jBinary": Equal(config.DefaultNeo4jBinary),
+				})),
+				"Readiness": PointTo(MatchAllFields(Fields{
+					"Interval": Equal(config.DefaultReadinessInterval),
+					"Timeout":  Equal(config.DefaultReadinessTimeout),
+					"Query":    BeEmpty(),
+					"Database": BeEmpty(),
+				})),
+				"Backup": PointTo(MatchAllFields(Fields{
+					"Dir":         Equal(config.DefaultBackupDir),
+					"Neo4jAdmin":  Equal(config.DefaultNeo4jAdmin),
+					"Database":    BeEmpty(),
+					"BeforeClean": BeFalse(),
+					"Keep":        BeZero(),
+				})),
+				"Shutdown": PointTo(MatchAllFields(Fields{
+					"MigrationTimeout": Equal(config.DefaultMigrationTimeout),
+					"KillTimeout":      Equal(config.DefaultKillTimeout),
+				})),
+				"Restart": PointTo(MatchAllFields(Fields{
+					"Policy":         Equal(config.DefaultRestartPolicy),
+					"MaxRestarts":    Equal(config.DefaultMaxRestarts),
+					"InitialBackoff": Equal(config.DefaultRestartBackoff),
+					"MaxBackoff":     Equal(config.DefaultRestartMaxBackoff),
+					"StableAfter":    Equal(config.DefaultRestartStableAfter),
+				})),
+			})),
+			"Connection": PointTo(MatchAllFields(Fields{
+				"URI":            Equal(config.DefaultConnectionURI),
+				"Username":       BeEmpty(),
+				"Password":       BeEmpty(),
+				"Database":       Equal("neo4j"),
+				"TLSCAFile":      BeEmpty(),
+				"Routing":        Equal(config.DefaultConnectionRouting),
+				"CreateDatabase": BeFalse(),
+			})),
+			"Tenants": PointTo(MatchAllFields(Fields{
+				"Databases":   BeEmpty(),
+				"File":        BeEmpty(),
+				"Query":       BeEmpty(),
+				"Parallelism": Equal(config.DefaultTenantsParallelism),
+			})),
+			"Tracing": PointTo(MatchAllFields(Fields{
+				"Endpoint":    BeEmpty(),
+				"Insecure":    BeFalse(),
+				"ServiceName": Equal(config.DefaultTracingServiceName),
 			})),
 			"Planner": PointTo(MatchAllFields(Fields{
 				"BaseFolder":        Equal(config.DefaultBaseFolder),
 				"DropCypherFile":    Equal(config.DefaultDropCypherFile),
 				"CypherShellFormat": Equal("auto"),
-				"AllowedCommands":   HaveLen(0),
-				"Batches":           HaveLen(0),
+				"ReportFile":        BeEmpty(),
+				"ProcessPerFile":    BeFalse(),
+				"WipeStrategy":      Equal(config.DefaultWipeStrategy),
+				"WipeBatchSize":     Equal(config.DefaultWipeBatchSize),
+				"Protected":         BeFalse(),
+				"ConfirmToken":      BeEmpty(),
+				"Retry": PointTo(MatchAllFields(Fields{
+					"MaxAttempts":    Equal(config.DefaultRetryMaxAttempts),
+					"InitialBackoff": Equal(config.DefaultRetryInitialBackoff),
+					"MaxBackoff":     Equal(config.DefaultRetryMaxBackoff),
+					"RetryableCodes": Equal(config.DefaultRetryableCodes),
+				})),
+				"AllowedCommands": HaveLen(0),
+				"Batches":         HaveLen(0),
 				"SchemaFolder": PointTo(MatchAllFields(Fields{
 					"FolderName":    Equal(config.DefaultSchemaFolderName),
 					"MigrationType": Equal(config.DefaultSchemaMigrationType),
 					"NodeLabels":    ConsistOf(config.DefaultNodeLabel, "SchemaVersion"),
+					"Database":      BeEmpty(),
 				})),
 				"Folders": HaveLen(0),
 			})),
@@ -349,6 +605,122 @@ var _ = Describe("Validation & Normalize", func() {
 			cfg.Supervisor.Port = 1000
 		}, MatchError("port number must be in range 1024 - 65535")),
 
+		Entry("Bind address with port", func(cfg *config.Config) {
+			cfg.Supervisor.BindAddress = "localhost:8080"
+		}, MatchError("bind_address 'localhost:8080' must be IP address or host name without port")),
+
+		Entry("TLS key without cert", func(cfg *config.Config) {
+			cfg.Supervisor.TLSKeyFile = "server.key"
+		}, MatchError("tls_cert_file and tls_key_file must be set together")),
+
+		Entry("Negative timeout", func(cfg *config.Config) {
+			cfg.Supervisor.IdleTimeout = -time.Second
+		}, MatchError("supervisor timeouts cannot be negative")),
+
+		Entry("Job history", func(cfg *config.Config) {
+			cfg.Supervisor.JobHistory = -1
+		}, MatchError("job_history cannot be negative")),
+
+		Entry("Log buffer", func(cfg *config.Config) {
+			cfg.Supervisor.LogBuffer = -1
+		}, MatchError("log_buffer cannot be negative")),
+
+		Entry("Empty auth token", func(cfg *config.Config) {
+			cfg.Supervisor.Auth = &config.SupervisorAuth{Tokens: []*config.AuthToken{{Role: config.RoleRead}}}
+		}, MatchError("auth token cannot be empty")),
+
+		Entry("Duplicate auth token", func(cfg *config.Config) {
+			cfg.Supervisor.Auth = &config.SupervisorAuth{Tokens: []*config.AuthToken{
+				{Token: "abc", Role: config.RoleRead},
+				{Token: "abc", Role: config.RoleAdmin},
+			}}
+		}, MatchError("duplicate auth token")),
+
+		Entry("Invalid auth token role", func(cfg *config.Config) {
+			cfg.Supervisor.Auth = &config.SupervisorAuth{Tokens: []*config.AuthToken{{Token: "abc", Role: "root"}}}
+		}, MatchError("auth role 'root' is invalid, must be one of 'read,admin'")),
+
+		Entry("Invalid auth username", func(cfg *config.Config) {
+			cfg.Supervisor.Auth = &config.SupervisorAuth{Users: []*config.AuthUser{
+				{Username: "a:b", Password: "pass", Role: config.RoleRead},
+			}}
+		}, MatchError("auth username cannot be empty or contain ':'")),
+
+		Entry("Duplicate auth user", func(cfg *config.Config) {
+			cfg.Supervisor.Auth = &config.SupervisorAuth{Users: []*config.AuthUser{
+				{Username: "john", Password: "pass", Role: config.RoleRead},
+				{Username: "john", Password: "pass", Role: config.RoleAdmin},
+			}}
+		}, MatchError("duplicate auth user 'john'")),
+
+		Entry("Empty auth password", func(cfg *config.Config) {
+			cfg.Supervisor.Auth = &config.SupervisorAuth{Users: []*config.AuthUser{
+				{Username: "john", Role: config.RoleRead},
+			}}
+		}, MatchError("auth password of user 'john' cannot be empty")),
+
+		Entry("Invalid auth user role", func(cfg *config.Config) {
+			cfg.Supervisor.Auth = &config.SupervisorAuth{Users: []*config.AuthUser{
+				{Username: "john", Password: "pass"},
+			}}
+		}, MatchError("auth role '' is invalid, must be one of 'read,admin'")),
+
+		Entry("Invalid webhook URL", func(cfg *config.Config) {
+			cfg.Supervisor.Webhooks = []*config.Webhook{{URL: "ftp://example.com/hook"}}
+		}, MatchError("webhook url 'ftp://example.com/hook' must be URL with http or https scheme")),
+
+		Entry("Invalid webhook event", func(cfg *config.Config) {
+			cfg.Supervisor.Webhooks = []*config.Webhook{{URL: "http://example.com", Events: []string{"neo4j.exploded"}}}
+		}, MatchError(ContainSubstring("webhook event 'neo4j.exploded' is invalid, must be one of"))),
+
+		Entry("Negative webhook max attempts", func(cfg *config.Config) {
+			cfg.Supervisor.Webhooks = []*config.Webhook{{URL: "http://example.com", MaxAttempts: -1}}
+		}, MatchError("webhook max_attempts cannot be negative")),
+
+		Entry("Negative webhook timeout", func(cfg *config.Config) {
+			cfg.Supervisor.Webhooks = []*config.Webhook{{URL: "http://example.com", Timeout: -time.Second}}
+		}, MatchError("webhook timeout cannot be negative")),
+
+		Entry("Invalid restart policy", func(cfg *config.Config) {
+			cfg.Supervisor.Restart = &config.RestartPolicy{Policy: "sometimes"}
+		}, MatchError("restart policy 'sometimes' is invalid, must be one of 'never,on-failure,always'")),
+
+		Entry("Negative max restarts", func(cfg *config.Config) {
+			cfg.Supervisor.Restart = &config.RestartPolicy{Policy: config.RestartAlways, MaxRestarts: -1}
+		}, MatchError("restart max_restarts cannot be negative")),
+
+		Entry("Restart max backoff lower than initial", func(cfg *config.Config) {
+			cfg.Supervisor.Restart = &config.RestartPolicy{
+				Policy:         config.RestartOnFailure,
+				InitialBackoff: time.Minute,
+				MaxBackoff:     time.Second,
+			}
+		}, MatchError("restart max_backoff cannot be lower than initial_backoff")),
+
+		Entry("Invalid process backend", func(cfg *config.Config) {
+			cfg.Supervisor.Process = &config.Process{Backend: "kubernetes"}
+		}, MatchError("process backend 'kubernetes' is invalid, must be one of 'docker,binary,external'")),
+
+		Entry("Negative readiness timeout", func(cfg *config.Config) {
+			cfg.Supervisor.Readiness = &config.Readiness{Timeout: -time.Second}
+		}, MatchError("readiness interval and timeout cannot be negative")),
+
+		Entry("Invalid readiness database", func(cfg *config.Config) {
+			cfg.Supervisor.Readiness = &config.Readiness{Database: "a"}
+		}, MatchError("in readiness database name 'a' is invalid")),
+
+		Entry("Negative backup keep", func(cfg *config.Config) {
+			cfg.Supervisor.Backup = &config.Backup{Keep: -1}
+		}, MatchError("backup keep cannot be negative")),
+
+		Entry("Invalid backup database", func(cfg *config.Config) {
+			cfg.Supervisor.Backup = &config.Backup{Database: "a"}
+		}, MatchError("in backup database name 'a' is invalid")),
+
+		Entry("Negative shutdown timeout", func(cfg *config.Config) {
+			cfg.Supervisor.Shutdown = &config.Shutdown{KillTimeout: -time.Second}
+		}, MatchError("shutdown migration_timeout and kill_timeout cannot be negative")),
+
 		Entry("Neo4j Auth", func(cfg *config.Config) {
 			cfg.Supervisor.Neo4jAuth = "abc"
 		}, MatchError("neo4j auth must be in format username/passsword")),
@@ -357,10 +729,128 @@ var _ = Describe("Validation & Normalize", func() {
 			cfg.Supervisor.LogLevel = "xxx"
 		}, MatchError("log_level value 'xxx' is invalid, must be one of 'fatal,error,warn,warning,info,debug,trace'")),
 
+		Entry("Connection URI scheme", func(cfg *config.Config) {
+			cfg.Connection = &config.Connection{URI: "http://localhost:7474"}
+		}, MatchError("connection uri 'http://localhost:7474' is invalid, "+
+			"scheme must be one of 'bolt,bolt+s,bolt+ssc,neo4j,neo4j+s,neo4j+ssc'")),
+
+		Entry("Connection routing", func(cfg *config.Config) {
+			cfg.Connection = &config.Connection{URI: "neo4j://localhost", Routing: "xxx"}
+		}, MatchError("connection routing value 'xxx' is invalid, must be one of 'auto,direct,routed'")),
+
+		Entry("Connection CA file without TLS", func(cfg *config.Config) {
+			cfg.Connection = &config.Connection{URI: "bolt://localhost", TLSCAFile: "ca.pem"}
+		}, MatchError("connection tls_ca_file can be used only with 'bolt+s' or 'neo4j+s' scheme")),
+
+		Entry("Connection CA file with self-signed certificate scheme", func(cfg *config.Config) {
+			cfg.Connection = &config.Connection{URI: "bolt+ssc://localhost", TLSCAFile: "ca.pem"}
+		}, MatchError("connection tls_ca_file cannot be used with 'bolt+ssc' scheme, which skips CA verification, "+
+			"use 'bolt+s' or 'neo4j+s' with custom CA")),
+
+		Entry("Connection CA file with routed self-signed certificate scheme", func(cfg *config.Config) {
+			cfg.Connection = &config.Connection{URI: "neo4j+ssc://localhost", TLSCAFile: "ca.pem"}
+		}, MatchError(ContainSubstring("cannot be used with 'neo4j+ssc' scheme"))),
+
+		Entry("Connection password without username", func(cfg *config.Config) {
+			cfg.Connection = &config.Connection{URI: "neo4j://localhost", Password: "secret"}
+		}, MatchError("connection password is set, but username is missing")),
+
+		Entry("Schema folder database", func(cfg *config.Config) {
+			cfg.Planner.SchemaFolder.Database = "a b"
+		}, MatchError("in folder schema database name 'a b' is invalid")),
+
+		Entry("Folder database", func(cfg *config.Config) {
+			cfg.Planner.Folders["data"].Database = "db"
+		}, MatchError("in folder 'data' database name 'db' is invalid")),
+
+		Entry("Batch database", func(cfg *config.Config) {
+			cfg.Planner.Batches["data"].Database = "-audit"
+		}, MatchError("in batch 'data' database name '-audit' is invalid")),
+
+		Entry("Connection database", func(cfg *config.Config) {
+			cfg.Connection = &config.Connection{URI: "neo4j://localhost", Database: "my db"}
+		}, MatchError("in connection database name 'my db' is invalid")),
+
+		Entry("Folder in batches with different databases", func(cfg *config.Config) {
+			cfg.Planner.Batches["data"].Database = "audit"
+			cfg.Planner.Batches["performance"].Database = "perf"
+		}, MatchError(ContainSubstring("folder 'data' is in batches with different databases"))),
+
+		Entry("Retry max attempts", func(cfg *config.Config) {
+			cfg.Planner.Retry = &config.RetryPolicy{MaxAttempts: 0}
+		}, MatchError("retry max_attempts must be at least 1")),
+
+		Entry("Retry negative backoff", func(cfg *config.Config) {
+			cfg.Planner.Retry = &config.RetryPolicy{MaxAttempts: 1, InitialBackoff: -time.Second}
+		}, MatchError("retry backoff cannot be negative")),
+
+		Entry("Retry max backoff", func(cfg *config.Config) {
+			cfg.Planner.Retry = &config.RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Second}
+		}, MatchError("retry max_backoff cannot be lower than initial_backoff")),
+
+		Entry("Retry empty code", func(cfg *config.Config) {
+			cfg.Planner.Retry = &config.RetryPolicy{MaxAttempts: 1, RetryableCodes: []string{""}}
+		}, MatchError("retryable code cannot be empty")),
+
+		Entry("Tracing endpoint without port", func(cfg *config.Config) {
+			cfg.Tracing = &config.Tracing{Endpoint: "otel-collector"}
+		}, MatchError("tracing endpoint 'otel-collector' must be host:port or URL with http or https scheme")),
+
+		Entry("Tracing endpoint with invalid scheme", func(cfg *config.Config) {
+			cfg.Tracing = &config.Tracing{Endpoint: "grpc://otel-collector:4317"}
+		}, MatchError(ContainSubstring("tracing endpoint 'grpc://otel-collector:4317' must be host:port or URL"))),
+
+		Entry("Tenants parallelism", func(cfg *config.Config) {
+			cfg.Tenants = &config.Tenants{Databases: []string{"tenant-a"}}
+		}, MatchError("tenants parallelism must be at least 1")),
+
+		Entry("Tenants duplicate database", func(cfg *config.Config) {
+			cfg.Tenants = &config.Tenants{Databases: []string{"tenant-a", "tenant-a"}, Parallelism: 1}
+		}, MatchError("duplicate tenant database 'tenant-a'")),
+
+		Entry("Tenants empty database", func(cfg *config.Config) {
+			cfg.Tenants = &config.Tenants{Databases: []string{""}, Parallelism: 1}
+		}, MatchError("tenant database name cannot be empty")),
+
+		Entry("Tenants invalid database", func(cfg *config.Config) {
+			cfg.Tenants = &config.Tenants{Databases: []string{"my tenant"}, Parallelism: 1}
+		}, MatchError("in tenants database name 'my tenant' is invalid")),
+
+		Entry("Tenants with folder database", func(cfg *config.Config) {
+			cfg.Tenants = &config.Tenants{File: "tenants.txt", Parallelism: 1}
+			cfg.Planner.Folders["perf"].Database = "perf"
+		}, MatchError("tenants cannot be used together with database of folder 'perf'")),
+
+		Entry("Tenants with batch database", func(cfg *config.Config) {
+			cfg.Tenants = &config.Tenants{Query: "SHOW DATABASES YIELD name", Parallelism: 1}
+			cfg.Planner.Batches["data"].Database = "audit"
+		}, MatchError("tenants cannot be used together with database of batch 'data'")),
+
+		Entry("Tenants with schema database", func(cfg *config.Config) {
+			cfg.Tenants = &config.Tenants{Databases: []string{"tenant-a"}, Parallelism: 1}
+			cfg.Planner.SchemaFolder.Database = "schema"
+		}, MatchError("tenants cannot be used together with database of schema folder")),
+
 		Entry("CypherShellFormat", func(cfg *config.Config) {
 			cfg.Planner.CypherShellFormat = "xxx"
 		}, MatchError("cypher_shell_format value 'xxx' is invalid, must be one of 'auto,verbose,plain'")),
 
+		Entry("WipeStrategy", func(cfg *config.Config) {
+			cfg.Planner.WipeStrategy = "truncate"
+		}, MatchError("wipe_strategy value 'truncate' is invalid, must be one of 'file,delete,replace'")),
+
+		Entry("Negative WipeBatchSize", func(cfg *config.Config) {
+			cfg.Planner.WipeBatchSize = -1
+		}, MatchError("wipe_batch_size cannot be negative")),
+
+		Entry("Protected without ConfirmToken", func(cfg *config.Config) {
+			cfg.Planner.Protected = true
+		}, MatchError("confirm_token cannot be empty, when any batch is protected")),
+
+		Entry("Protected batch without ConfirmToken", func(cfg *config.Config) {
+			cfg.Planner.Batches["data"].Protected = true
+		}, MatchError("confirm_token cannot be empty, when any batch is protected")),
+
 		Entry("Graph Version", func(cfg *config.Config) {
 			cfg.Supervisor.DefaultGraphVersion = "www"
 		}, MatchError(ContainSubstring("invalid semantic version"))),
@@ -414,6 +904,120 @@ var _ = Describe("Validation & Normalize", func() {
 			f := cfg.Planner.Folders["superdata"]
 			Expect(f.MigrationType).To(Equal("change"))
 			Expect(f.NodeLabels).To(ConsistOf(config.DefaultNodeLabel, "SuperdataVersion"))
+			Expect(cfg.Planner.Retry).To(PointTo(MatchAllFields(Fields{
+				"MaxAttempts":    Equal(config.DefaultRetryMaxAttempts),
+				"InitialBackoff": Equal(config.DefaultRetryInitialBackoff),
+				"MaxBackoff":     Equal(config.DefaultRetryMaxBackoff),
+				"RetryableCodes": Equal(config.DefaultRetryableCodes),
+			})))
+			Expect(cfg.Tenants).To(PointTo(MatchAllFields(Fields{
+				"Databases":   BeEmpty(),
+				"File":        BeEmpty(),
+				"Query":       BeEmpty(),
+				"Parallelism": Equal(config.DefaultTenantsParallelism),
+			})))
+			Expect(cfg.Tenants.Configured()).To(BeFalse())
+			Expect(cfg.Tracing).To(PointTo(MatchAllFields(Fields{
+				"Endpoint":    BeEmpty(),
+				"Insecure":    BeFalse(),
+				"ServiceName": Equal(config.DefaultTracingServiceName),
+			})))
+			Expect(cfg.Tracing.Enabled()).To(BeFalse())
+		})
+
+		It("Connection falls back to supervisor settings", func() {
+			configStruct.Supervisor.Neo4jDatabase = "my_db"
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Connection).To(PointTo(MatchAllFields(Fields{
+				"URI":            Equal(config.DefaultConnectionURI),
+				"Username":       Equal("name"),
+				"Password":       Equal("pass"),
+				"Database":       Equal("my_db"),
+				"TLSCAFile":      BeEmpty(),
+				"Routing":        Equal(config.DefaultConnectionRouting),
+				"CreateDatabase": BeFalse(),
+			})))
+
+			configStruct.Connection = &config.Connection{URI: "neo4j+s://remote", Username: "ci", Database: "db"}
+			err = configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Connection).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"URI":      Equal("neo4j+s://remote"),
+				"Username": Equal("ci"),
+				"Password": BeEmpty(),
+				"Database": Equal("db"),
+			})))
+		})
+
+		It("Keeps retry policy and fills only missing values", func() {
+			configStruct.Planner.Retry = &config.RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Minute}
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Planner.Retry).To(PointTo(MatchAllFields(Fields{
+				"MaxAttempts":    Equal(1),
+				"InitialBackoff": Equal(time.Minute),
+				"MaxBackoff":     Equal(time.Minute),
+				"RetryableCodes": Equal(config.DefaultRetryableCodes),
+			})))
+		})
+
+		It("Restart policy keeps configured values", func() {
+			configStruct.Supervisor.Restart = &config.RestartPolicy{
+				Policy:         config.RestartNever,
+				InitialBackoff: 2 * time.Minute,
+			}
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Supervisor.Restart).To(PointTo(MatchAllFields(Fields{
+				"Policy":         Equal(config.RestartNever),
+				"MaxRestarts":    BeZero(),
+				"InitialBackoff": Equal(2 * time.Minute),
+				"MaxBackoff":     Equal(2 * time.Minute),
+				"StableAfter":    Equal(config.DefaultRestartStableAfter),
+			})))
+		})
+
+		It("Readiness waits 5 minutes by default", func() {
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Supervisor.Readiness).To(PointTo(MatchAllFields(Fields{
+				"Interval": Equal(config.DefaultReadinessInterval),
+				"Timeout":  Equal(config.DefaultReadinessTimeout),
+				"Query":    BeEmpty(),
+				"Database": BeEmpty(),
+			})))
+		})
+
+		It("Shutdown waits for migration and Neo4j by default", func() {
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Supervisor.Shutdown).To(PointTo(MatchAllFields(Fields{
+				"MigrationTimeout": Equal(config.DefaultMigrationTimeout),
+				"KillTimeout":      Equal(config.DefaultKillTimeout),
+			})))
+		})
+
+		It("Backup uses default directory and neo4j-admin", func() {
+			configStruct.Supervisor.Backup = &config.Backup{Keep: 2}
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Supervisor.Backup).To(PointTo(MatchAllFields(Fields{
+				"Dir":         Equal(config.DefaultBackupDir),
+				"Neo4jAdmin":  Equal(config.DefaultNeo4jAdmin),
+				"Database":    BeEmpty(),
+				"BeforeClean": BeFalse(),
+				"Keep":        Equal(2),
+			})))
+		})
+
+		It("Tenants keep configured values", func() {
+			configStruct.Tenants = &config.Tenants{Databases: []string{"tenant-a"}, Parallelism: 2}
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Tenants.Parallelism).To(Equal(2))
+			Expect(configStruct.Tenants.Configured()).To(BeTrue())
+			Expect(configStruct.Validate()).To(Succeed())
 		})
 
 		It("Missing NodeLabels", func() {
@@ -431,6 +1035,15 @@ var _ = Describe("Validation & Normalize", func() {
 			})))
 		})
 
+		It("Wipe uses drop file by default", func() {
+			err := configStruct.Normalize()
+			Expect(err).To(Succeed())
+			Expect(configStruct.Planner).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"WipeStrategy":  Equal(config.WipeFile),
+				"WipeBatchSize": Equal(config.DefaultWipeBatchSize),
+			})))
+		})
+
 		It("Case insensitive", func() {
 			configStruct.Supervisor.LogLevel = "fAtAL"
 			err := configStruct.Normalize()
@@ -438,6 +1051,9 @@ var _ = Describe("Validation & Normalize", func() {
 			Expect(configStruct).To(PointTo(MatchFields(IgnoreExtras, Fields{
 				"Supervisor": PointTo(MatchFields(IgnoreExtras, Fields{
 					"LogLevel": Equal("fatal"),
+					// Zero keeps no jobs and log lines, only viper sets defaults.
+					"JobHistory": BeZero(),
+					"LogBuffer":  BeZero(),
 				})),
 			})))
 		})
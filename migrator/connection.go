@@ -0,0 +1,116 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	neo4jcfg "github.com/neo4j/neo4j-go-driver/v6/neo4j/config"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+const (
+	EnvNeo4jUsername = "NEO4J_USERNAME"
+	EnvNeo4jPassword = "NEO4J_PASSWORD"
+	EnvNeo4jDatabase = "NEO4J_DATABASE"
+)
+
+// ConnectionURI returns URI from connection config with scheme changed according to routing.
+func ConnectionURI(conn *config.Connection) string {
+	scheme, rest, _ := strings.Cut(conn.URI, "://")
+	switch conn.Routing {
+	case "direct":
+		scheme = strings.Replace(scheme, "neo4j", "bolt", 1)
+	case "routed":
+		scheme = strings.Replace(scheme, "bolt", "neo4j", 1)
+	}
+	return scheme + "://" + rest
+}
+
+// ConnectionEnv returns environment variables, which cypher-shell uses to connect to DB.
+// The same variables are passed to commands executed from .run files.
+func ConnectionEnv(conn *config.Connection) []string {
+	env := []string{
+		EnvNeo4jURI + "=" + ConnectionURI(conn),
+		EnvNeo4jUsername + "=" + conn.Username,
+		EnvNeo4jPassword + "=" + conn.Password,
+	}
+	if conn.Database != "" {
+		env = append(env, EnvNeo4jDatabase+"="+conn.Database)
+	}
+	return env
+}
+
+// NewDriver creates Neo4j driver from connection config.
+// Basic auth is used when username is set, otherwise no authentication is used.
+func NewDriver(conn *config.Connection) (neo4j.Driver, error) {
+	if conn == nil {
+		return nil, errors.New("missing config.Connection")
+	}
+
+	authToken := neo4j.NoAuth()
+	if conn.Username != "" {
+		authToken = neo4j.BasicAuth(conn.Username, conn.Password, "")
+	}
+
+	var tlsConfig *tls.Config
+	if conn.TLSCAFile != "" {
+		pem, err := os.ReadFile(filepath.Clean(conn.TLSCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read TLS CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in TLS CA file '%s'", conn.TLSCAFile)
+		}
+		tlsConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return neo4j.NewDriver(ConnectionURI(conn), authToken, func(c *neo4jcfg.Config) {
+		if tlsConfig != nil {
+			c.TlsConfig = tlsConfig
+		}
+	})
+}
+
+// NewSessionFactory returns SessionFactory, which opens sessions with given access mode.
+// Database from connection config is used, when the requested one is empty.
+func NewSessionFactory(driver neo4j.Driver, conn *config.Connection, mode neo4j.AccessMode) SessionFactory {
+	return func(ctx context.Context, database string) neo4j.Session {
+		if database == "" {
+			database = conn.Database
+		}
+		return driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: mode, DatabaseName: database})
+	}
+}
+
+// NewSession creates session to the database from connection config.
+func NewSession(
+	ctx context.Context,
+	driver neo4j.Driver,
+	conn *config.Connection,
+	mode neo4j.AccessMode,
+) neo4j.Session {
+	return driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: mode, DatabaseName: conn.Database})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection", func() {
+	DescribeTable("ConnectionURI",
+		func(uri, routing, expected string) {
+			Expect(migrator.ConnectionURI(&config.Connection{URI: uri, Routing: routing})).To(Equal(expected))
+		},
+		Entry("Auto keeps bolt", "bolt://localhost:7687", "auto", "bolt://localhost:7687"),
+		Entry("Auto keeps neo4j", "neo4j+s://db.example.com", "auto", "neo4j+s://db.example.com"),
+		Entry("Direct", "neo4j+ssc://db.example.com", "direct", "bolt+ssc://db.example.com"),
+		Entry("Routed", "bolt+s://db.example.com:7687", "routed", "neo4j+s://db.example.com:7687"),
+		Entry("Direct keeps bolt", "bolt://localhost", "direct", "bolt://localhost"),
+	)
+
+	It("ConnectionEnv", func() {
+		Expect(migrator.ConnectionEnv(&config.Connection{
+			URI: "neo4j://localhost", Routing: "direct", Username: "user", Password: "pass", Database: "db",
+		})).To(Equal([]string{
+			"NEO4J_URI=bolt://localhost", "NEO4J_USERNAME=user", "NEO4J_PASSWORD=pass", "NEO4J_DATABASE=db",
+		}))
+		Expect(migrator.ConnectionEnv(&config.Connection{URI: "neo4j://localhost"})).
+			NotTo(ContainElement(HavePrefix("NEO4J_DATABASE=")))
+	})
+
+	It("NewDriver", func() {
+		_, err := migrator.NewDriver(nil)
+		Expect(err).To(MatchError("missing config.Connection"))
+
+		driver, err := migrator.NewDriver(&config.Connection{URI: "neo4j://localhost", Username: "user"})
+		Expect(err).To(Succeed())
+		Expect(driver.Target().Scheme).To(Equal("neo4j"))
+		Expect(driver.Close(context.Background())).To(Succeed())
+
+		caFile := filepath.Join(GinkgoT().TempDir(), "ca.pem")
+		_, err = migrator.NewDriver(&config.Connection{URI: "neo4j+s://localhost", TLSCAFile: caFile})
+		Expect(err).To(MatchError(ContainSubstring("cannot read TLS CA file")))
+
+		Expect(os.WriteFile(caFile, []byte("not a certificate"), 0o600)).To(Succeed())
+		_, err = migrator.NewDriver(&config.Connection{URI: "neo4j+s://localhost", TLSCAFile: caFile})
+		Expect(err).To(MatchError("no certificate found in TLS CA file '" + caFile + "'"))
+	})
+})
@@ -0,0 +1,197 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+const (
+	showDatabasesCypher  = `SHOW DATABASES YIELD name RETURN DISTINCT name`
+	editionCypher        = `CALL dbms.components() YIELD edition RETURN edition`
+	createDatabaseCypher = `CREATE DATABASE $name IF NOT EXISTS WAIT`
+)
+
+// DatabaseModels holds DatabaseModel per database name. Empty name means default database of the session.
+type DatabaseModels map[string]DatabaseModel
+
+// Merge returns single DatabaseModel with folders from all databases.
+// Folder names are unique across databases, so nothing is lost.
+func (dbms DatabaseModels) Merge() DatabaseModel {
+	merged := make(DatabaseModel)
+	for _, dbm := range dbms {
+		maps.Copy(merged, dbm)
+	}
+	return merged
+}
+
+// Databases returns sorted names of all databases used by schema folder and folders of given batch.
+// Folders without database use the one from config.Connection, which might be empty for the default database.
+func (p *Planner) Databases(batch Batch) []string {
+	dbs := map[string]bool{p.resolveDatabase(p.folderDatabase(p.config.Planner.SchemaFolder.FolderName)): true}
+	if b := p.config.Planner.Batches[string(batch)]; b != nil {
+		for _, f := range b.Folders {
+			dbs[p.resolveDatabase(p.folderDatabase(f))] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(dbs))
+}
+
+// CreateDatabases creates databases of schema folder and folders of given batch, which do not exist yet.
+// See CreateDatabases function.
+func (p *Planner) CreateDatabases(ctx context.Context, newSession SessionFactory, batch Batch) ([]string, error) {
+	return CreateDatabases(ctx, newSession, p.Databases(batch))
+}
+
+// CreateDatabases creates given databases, which do not exist yet, and waits until they are online.
+// Empty name means default database, which always exists. Names of created databases are returned.
+// Only Enterprise edition can create databases, error with names of missing databases is returned otherwise.
+// Session factory must open sessions in write mode.
+func CreateDatabases(ctx context.Context, newSession SessionFactory, databases []string) ([]string, error) {
+	session := newSession(ctx, SystemDatabase)
+	defer func() { _ = session.Close(ctx) }()
+
+	existing, err := queryStrings(ctx, session, showDatabasesCypher)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list databases: %w", err)
+	}
+	var missing []string
+	for _, db := range databases {
+		if db != "" && !slices.Contains(existing, db) && !slices.Contains(missing, db) {
+			missing = append(missing, db)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	editions, err := queryStrings(ctx, session, editionCypher)
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect Neo4j edition: %w", err)
+	}
+	if !slices.Contains(editions, "enterprise") {
+		return nil, fmt.Errorf("cannot create missing databases '%s' in Neo4j %s edition, "+
+			"create them manually or use Enterprise edition",
+			strings.Join(missing, "', '"), strings.Join(editions, ","))
+	}
+
+	created := make([]string, 0, len(missing))
+	for _, db := range missing {
+		// Administration commands are executed in auto-commit transaction.
+		result, err := session.Run(ctx, createDatabaseCypher, map[string]any{"name": db})
+		if err == nil {
+			_, err = result.Consume(ctx)
+		}
+		if err != nil {
+			return created, fmt.Errorf("cannot create database '%s': %w", db, err)
+		}
+		created = append(created, db)
+	}
+	return created, nil
+}
+
+// queryStrings returns values of the first column of all records.
+func queryStrings(ctx context.Context, session neo4j.Session, cypher string) ([]string, error) {
+	res, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, cypher, nil)
+		if err != nil {
+			return nil, err
+		}
+		records, err := result.Collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]string, 0, len(records))
+		for _, record := range records {
+			if len(record.Values) == 0 {
+				return nil, fmt.Errorf("no value returned by '%s'", cypher)
+			}
+			v, ok := record.Values[0].(string)
+			if !ok || v == "" {
+				return nil, fmt.Errorf("invalid value '%v' returned by '%s'", record.Values[0], cypher)
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	values, _ := res.([]string)
+	return values, nil
+}
+
+// foldersByDatabase returns names of all configured folders grouped by resolved database.
+func (p *Planner) foldersByDatabase() map[string][]string {
+	folders := map[string][]string{}
+	add := func(name string) {
+		db := p.resolveDatabase(p.folderDatabase(name))
+		folders[db] = append(folders[db], name)
+	}
+	add(p.config.Planner.SchemaFolder.FolderName)
+	for name := range p.config.Planner.Folders {
+		add(name)
+	}
+	for _, names := range folders {
+		sort.Strings(names)
+	}
+	return folders
+}
+
+// folderDatabase returns database explicitly configured for the folder, or the database of the batch containing
+// the folder. Snapshots are stored in the database of schema folder. Empty string is returned if not configured.
+func (p *Planner) folderDatabase(folderName string) string {
+	schema := p.config.Planner.SchemaFolder
+	if folderName == schema.FolderName || folderName == snapshotsFolder {
+		return schema.Database
+	}
+	folder := p.config.Planner.Folders[folderName]
+	if folder == nil {
+		return ""
+	}
+	if folder.Database != "" {
+		return folder.Database
+	}
+	// Config validation guarantees, that all batches with database agree on it.
+	for _, b := range p.config.Planner.Batches {
+		if b != nil && b.Database != "" && slices.Contains(b.Folders, folderName) {
+			return b.Database
+		}
+	}
+	return ""
+}
+
+// resolveDatabase returns the database itself, or database from connection config if empty.
+func (p *Planner) resolveDatabase(database string) string {
+	if database == "" {
+		return p.connectionDatabase()
+	}
+	return database
+}
+
+// connectionDatabase returns database from connection config, empty for the default database.
+func (p *Planner) connectionDatabase() string {
+	if p.config.Connection != nil {
+		return p.config.Connection.Database
+	}
+	return ""
+}
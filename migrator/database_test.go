@@ -0,0 +1,369 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j/db"
+	"go.uber.org/mock/gomock"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+	"github.com/indykite/neo4j-graph-tool-core/test"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multiple databases", func() {
+	var (
+		cfg          *config.Config
+		p            *migrator.Planner
+		localFolders migrator.LocalFolders
+	)
+
+	BeforeEach(func() {
+		cfg = &config.Config{
+			Planner: &config.Planner{
+				BaseFolder:     "import",
+				DropCypherFile: config.DefaultDropCypherFile,
+				SchemaFolder: &config.SchemaFolder{
+					FolderName:    "schema",
+					MigrationType: config.DefaultSchemaMigrationType,
+				},
+				AllowedCommands: map[string]string{"graph-tool": "/app/graph-tool"},
+				Folders: map[string]*config.FolderDetail{
+					"data": {MigrationType: config.DefaultFolderMigrationType, NodeLabels: []string{"DataVersion"}},
+					"perf": {MigrationType: "up_down"},
+				},
+				Batches: map[string]*config.BatchDetail{
+					"seed":      {Folders: []string{"data"}, Database: "audit"},
+					"perf-seed": {Folders: []string{"data", "perf"}},
+				},
+			},
+			Connection: &config.Connection{URI: config.DefaultConnectionURI, Database: "neo4j"},
+		}
+		Expect(cfg.Normalize()).To(Succeed())
+
+		var err error
+		p, err = migrator.NewPlanner(cfg)
+		Expect(err).To(Succeed())
+
+		s, err := p.NewScanner("testdata/import")
+		Expect(err).To(Succeed())
+		localFolders, err = s.ScanFolders()
+		Expect(err).To(Succeed())
+	})
+
+	It("Returns databases of the batch", func() {
+		Expect(p.Databases("schema")).To(Equal([]string{"neo4j"}))
+		Expect(p.Databases("seed")).To(Equal([]string{"audit", "neo4j"}))
+		// Folder takes database from other batch, which specifies it.
+		Expect(p.Databases("perf-seed")).To(Equal([]string{"audit", "neo4j"}))
+	})
+
+	It("Plans migrations into databases of folders without snapshots", func() {
+		steps := new(migrator.ExecutionSteps)
+		err := p.Plan(localFolders, nil, nil, "perf-seed", p.CreateBuilder(steps, false))
+		Expect(err).To(Succeed())
+
+		Expect(steps.String()).To(ContainSubstring("// Importing folder data - ver:1.0.0+1400\n" +
+			":use audit\n" +
+			":param migration_started_at => timestamp();\n"))
+
+		databases := map[string]bool{}
+		for _, s := range *steps {
+			mc := s.Migration()
+			Expect(mc.IsSnapshot).To(BeFalse())
+			if mc.FolderName == "data" {
+				Expect(mc.Database).To(Equal("audit"))
+			} else {
+				Expect(mc.FolderName).To(BeElementOf("schema", "perf"))
+				Expect(mc.Database).To(Equal("neo4j"))
+			}
+			databases[mc.Database] = true
+			if s.IsCypher() && !strings.HasPrefix(s.Cypher().String(), "// Running command") {
+				Expect(s.Cypher().String()).To(ContainSubstring(":use " + mc.Database + "\n"))
+			} else if !s.IsCypher() {
+				Expect(mc.Env()).To(ContainElement("NEO4J_DATABASE=" + mc.Database))
+			}
+		}
+		Expect(databases).To(HaveLen(2))
+	})
+
+	It("Selects single database, which differs from the connection one", func() {
+		cfg.Planner.SchemaFolder.Database = "audit"
+		cfg.Planner.Batches = nil
+		cfg.Planner.Folders = nil
+		var err error
+		p, err = migrator.NewPlanner(cfg)
+		Expect(err).To(Succeed())
+
+		steps := new(migrator.ExecutionSteps)
+		Expect(p.AddDrop(steps, "/import", "schema")).To(Succeed())
+		Expect(steps.String()).To(Equal("// wipe out the entire database\n:use audit\n:source /import/drop.cypher\n"))
+
+		steps = new(migrator.ExecutionSteps)
+		Expect(p.Plan(localFolders, nil, nil, "schema", p.CreateBuilder(steps, false))).To(Succeed())
+		Expect(*steps).NotTo(BeEmpty())
+		for _, s := range *steps {
+			Expect(s.Migration().Database).To(Equal("audit"))
+			if s.IsCypher() && !strings.HasPrefix(s.Cypher().String(), "// Running command") {
+				Expect(s.Cypher().String()).To(ContainSubstring(":use audit\n"))
+			}
+		}
+
+		cfg.Connection.Database = "audit"
+		steps = new(migrator.ExecutionSteps)
+		Expect(p.Plan(localFolders, nil, nil, "schema", p.CreateBuilder(steps, false))).To(Succeed())
+		Expect(steps.String()).NotTo(ContainSubstring(":use"))
+	})
+
+	It("Protects databases of protected batches", func() {
+		Expect(p.IsDatabaseProtected("audit")).To(BeFalse())
+		Expect(p.CheckRestoreProtection("audit", "")).To(Succeed())
+
+		cfg.Planner.Batches["seed"].Protected = true
+		cfg.Planner.ConfirmToken = "staging"
+		Expect(p.IsDatabaseProtected("audit")).To(BeTrue())
+		Expect(p.IsDatabaseProtected("other")).To(BeFalse())
+		// Empty name is the connection database, which stores schema folder of the batch.
+		Expect(p.IsDatabaseProtected("")).To(BeTrue())
+
+		err := p.CheckRestoreProtection("audit", "")
+		Expect(err).To(MatchError(migrator.ErrProtected))
+		Expect(err).To(MatchError("batch is protected, restore of database 'audit' requires confirmation token"))
+		Expect(p.CheckRestoreProtection("", "invalid")).To(MatchError(
+			"batch is protected, invalid confirmation token for restore of database 'neo4j'"))
+		Expect(p.CheckRestoreProtection("audit", "staging")).To(Succeed())
+		Expect(p.CheckRestoreProtection("other", "")).To(Succeed())
+	})
+
+	It("Protects clean run of batch sharing database with protected batch", func() {
+		Expect(p.CheckProtection("perf-seed", true, nil, "")).To(Succeed())
+
+		cfg.Planner.Batches["seed"].Protected = true
+		cfg.Planner.ConfirmToken = "staging"
+		Expect(p.IsProtected("perf-seed")).To(BeFalse())
+		Expect(p.CheckProtection("perf-seed", true, nil, "")).To(MatchError(
+			"batch is protected, wiping out of batch 'perf-seed' requires confirmation token"))
+		Expect(p.CheckProtection("schema", true, nil, "invalid")).To(MatchError(migrator.ErrProtected))
+		Expect(p.CheckProtection("perf-seed", true, nil, "staging")).To(Succeed())
+		// Only clean run wipes out shared databases.
+		Expect(p.CheckProtection("perf-seed", false, nil, "")).To(Succeed())
+	})
+
+	It("Drops all databases of the batch", func() {
+		steps := new(migrator.ExecutionSteps)
+		Expect(p.AddDrop(steps, "/import", "seed")).To(Succeed())
+		Expect(steps.String()).To(Equal("// wipe out the entire database audit\n" +
+			":use audit\n" +
+			":source /import/drop.cypher\n" +
+			"// wipe out the entire database neo4j\n" +
+			":use neo4j\n" +
+			":source /import/drop.cypher\n"))
+
+		steps = new(migrator.ExecutionSteps)
+		Expect(p.AddDrop(steps, "/import", "schema")).To(Succeed())
+		Expect(steps.String()).To(Equal("// wipe out the entire database\n:source /import/drop.cypher\n"))
+	})
+
+	It("Fetches versions from all databases", func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		transactions := map[string]*test.MockManagedTransaction{
+			"audit": test.NewMockManagedTransaction(mockCtrl),
+			"neo4j": test.NewMockManagedTransaction(mockCtrl),
+		}
+		expectQuery := func(database, labels string, files ...int64) {
+			transactions[database].EXPECT().Run(
+				gomock.Any(),
+				"MATCH (sm:"+labels+") WHERE sm.deleted_at IS NULL RETURN sm.version AS version, collect(sm.file) AS files", //nolint:lll
+				nil,
+			).DoAndReturn(func(_, _, _ any) (neo4j.Result, error) {
+				result := test.NewMockResult(mockCtrl)
+				if len(files) > 0 {
+					anyFiles := make([]any, 0, len(files))
+					for _, f := range files {
+						anyFiles = append(anyFiles, f)
+					}
+					result.EXPECT().Next(gomock.Any()).Return(true)
+					result.EXPECT().Record().Return(&db.Record{
+						Keys:   []string{"version", "files"},
+						Values: []any{"1.0.0", anyFiles},
+					})
+				}
+				result.EXPECT().Next(gomock.Any()).Return(false)
+				result.EXPECT().Err().Return(nil).AnyTimes()
+				result.EXPECT().Consume(gomock.Any()).Return(nil, nil)
+				return result, nil
+			})
+		}
+		expectQuery("audit", "DataVersion", 1400)
+		expectQuery("neo4j", "GraphToolMigration:SchemaVersion", 1000)
+		expectQuery("neo4j", "GraphToolMigration:PerfVersion")
+
+		var requested []string
+		dbModels, err := p.Versions(context.Background(), func(_ context.Context, database string) neo4j.Session {
+			requested = append(requested, database)
+			return &MockSession{tx: transactions[database]}
+		})
+		Expect(err).To(Succeed())
+		Expect(requested).To(ConsistOf("audit", "neo4j"))
+		Expect(dbModels).To(HaveLen(2))
+		Expect(dbModels["audit"]).To(HaveKey("data"))
+		Expect(dbModels["neo4j"]).To(HaveKey("schema"))
+		Expect(dbModels["neo4j"]).NotTo(HaveKey("perf"))
+		Expect(dbModels.Merge()).To(HaveLen(2))
+		Expect(dbModels.Merge().GetFileTimestamps("data", v100)).To(HaveKey(int64(1400)))
+	})
+
+	Describe("CreateDatabases", func() {
+		var (
+			mockCtrl *gomock.Controller
+			mockTx   *test.MockManagedTransaction
+			session  migrator.SessionFactory
+		)
+
+		expectStrings := func(cypher string, values ...string) {
+			records := make([]*db.Record, 0, len(values))
+			for _, v := range values {
+				records = append(records, &db.Record{Keys: []string{"name"}, Values: []any{v}})
+			}
+			result := test.NewMockResult(mockCtrl)
+			result.EXPECT().Collect(gomock.Any()).Return(records, nil)
+			mockTx.EXPECT().Run(gomock.Any(), cypher, nil).Return(result, nil)
+		}
+
+		BeforeEach(func() {
+			mockCtrl = gomock.NewController(GinkgoT())
+			mockTx = test.NewMockManagedTransaction(mockCtrl)
+			session = func(_ context.Context, database string) neo4j.Session {
+				Expect(database).To(Equal(migrator.SystemDatabase))
+				return &MockSession{tx: mockTx}
+			}
+		})
+
+		It("Does nothing when all databases exist", func() {
+			expectStrings("SHOW DATABASES YIELD name RETURN DISTINCT name", "system", "neo4j", "audit")
+
+			created, err := p.CreateDatabases(context.Background(), session, "seed")
+			Expect(err).To(Succeed())
+			Expect(created).To(BeEmpty())
+		})
+
+		It("Creates missing databases in Enterprise edition", func() {
+			expectStrings("SHOW DATABASES YIELD name RETURN DISTINCT name", "system", "neo4j")
+			expectStrings("CALL dbms.components() YIELD edition RETURN edition", "enterprise")
+			result := test.NewMockResult(mockCtrl)
+			result.EXPECT().Consume(gomock.Any()).Return(nil, nil)
+			mockTx.EXPECT().
+				Run(gomock.Any(), "CREATE DATABASE $name IF NOT EXISTS WAIT", map[string]any{"name": "audit"}).
+				Return(result, nil)
+
+			created, err := p.CreateDatabases(context.Background(), session, "seed")
+			Expect(err).To(Succeed())
+			Expect(created).To(Equal([]string{"audit"}))
+		})
+
+		It("Fails with clear message in Community edition", func() {
+			expectStrings("SHOW DATABASES YIELD name RETURN DISTINCT name", "system")
+			expectStrings("CALL dbms.components() YIELD edition RETURN edition", "community")
+
+			_, err := p.CreateDatabases(context.Background(), session, "seed")
+			Expect(err).To(MatchError("cannot create missing databases 'audit', 'neo4j' in Neo4j community edition, " +
+				"create them manually or use Enterprise edition"))
+		})
+	})
+
+	Describe("AddWipe", func() {
+		var (
+			mockCtrl  *gomock.Controller
+			mockTx    *test.MockManagedTransaction
+			requested []string
+			session   migrator.SessionFactory
+		)
+
+		expectStrings := func(cypher string, values ...string) {
+			records := make([]*db.Record, 0, len(values))
+			for _, v := range values {
+				records = append(records, &db.Record{Keys: []string{"name"}, Values: []any{v}})
+			}
+			result := test.NewMockResult(mockCtrl)
+			result.EXPECT().Collect(gomock.Any()).Return(records, nil)
+			mockTx.EXPECT().Run(gomock.Any(), cypher, nil).Return(result, nil)
+		}
+
+		BeforeEach(func() {
+			mockCtrl = gomock.NewController(GinkgoT())
+			mockTx = test.NewMockManagedTransaction(mockCtrl)
+			requested = nil
+			session = func(_ context.Context, database string) neo4j.Session {
+				requested = append(requested, database)
+				return &MockSession{tx: mockTx}
+			}
+		})
+
+		It("Uses drop file by default", func() {
+			steps := new(migrator.ExecutionSteps)
+			Expect(p.AddWipe(context.Background(), steps, "/import", "schema", session)).To(Succeed())
+			Expect(steps.String()).To(Equal("// wipe out the entire database\n:source /import/drop.cypher\n"))
+			Expect(requested).To(BeEmpty())
+		})
+
+		It("Deletes nodes and drops constraints and indexes", func() {
+			cfg.Planner.WipeStrategy = config.WipeDelete
+			cfg.Planner.WipeBatchSize = 500
+			expectStrings("SHOW CONSTRAINTS YIELD name RETURN name", "unique_user", "odd`name")
+			expectStrings("SHOW INDEXES YIELD name, type, owningConstraint "+
+				"WHERE owningConstraint IS NULL AND type <> 'LOOKUP' RETURN name", "user_email")
+
+			steps := new(migrator.ExecutionSteps)
+			Expect(p.AddWipe(context.Background(), steps, "/import", "schema", session)).To(Succeed())
+			Expect(steps.String()).To(Equal("// wipe out the entire database\n" +
+				"MATCH (n) CALL { WITH n DETACH DELETE n } IN TRANSACTIONS OF 500 ROWS;\n" +
+				"DROP CONSTRAINT `odd``name` IF EXISTS;\n" +
+				"DROP CONSTRAINT `unique_user` IF EXISTS;\n" +
+				"DROP INDEX `user_email` IF EXISTS;\n"))
+			Expect(requested).To(Equal([]string{"neo4j"}))
+		})
+
+		It("Replaces all databases of the batch in Enterprise edition", func() {
+			cfg.Planner.WipeStrategy = config.WipeReplace
+			expectStrings("CALL dbms.components() YIELD edition RETURN edition", "enterprise")
+
+			steps := new(migrator.ExecutionSteps)
+			Expect(p.AddWipe(context.Background(), steps, "/import", "seed", session)).To(Succeed())
+			Expect(steps.String()).To(Equal("// wipe out the entire database audit\n" +
+				":use system\n" +
+				"CREATE OR REPLACE DATABASE `audit` WAIT;\n" +
+				"// wipe out the entire database neo4j\n" +
+				":use system\n" +
+				"CREATE OR REPLACE DATABASE `neo4j` WAIT;\n"))
+			Expect(requested).To(Equal([]string{migrator.SystemDatabase}))
+		})
+
+		It("Refuses to replace database in Community edition", func() {
+			cfg.Planner.WipeStrategy = config.WipeReplace
+			expectStrings("CALL dbms.components() YIELD edition RETURN edition", "community")
+
+			err := p.AddWipe(context.Background(), new(migrator.ExecutionSteps), "/import", "seed", session)
+			Expect(err).To(MatchError(
+				"replace wipe strategy requires Enterprise edition, but Neo4j is community edition"))
+		})
+	})
+})
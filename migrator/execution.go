@@ -16,6 +16,7 @@ package migrator
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -27,10 +28,37 @@ import (
 
 type (
 	ExecutionStep struct {
-		cypher  *bytes.Buffer
-		command []string
+		cypher    *bytes.Buffer
+		command   []string
+		migration *MigrationContext
 	}
 	ExecutionSteps []ExecutionStep
+
+	// MigrationContext describes the migration file, which produced the execution step.
+	MigrationContext struct {
+		FolderName  string          `json:"folder"`
+		Path        string          `json:"path"`
+		Version     *semver.Version `json:"version"`
+		Revision    int64           `json:"revision,omitempty"`
+		IsDowngrade bool            `json:"is_downgrade,omitempty"`
+		IsSnapshot  bool            `json:"is_snapshot,omitempty"`
+		// Database is set only when folders are mapped to multiple databases.
+		Database string `json:"database,omitempty"`
+	}
+)
+
+// startedAtParam sets the time, when migration file started. Executor replaces it for commands with real time.
+const startedAtParam = ":param migration_started_at => timestamp();\n"
+
+// Environment variables passed to commands started from *.run files.
+const (
+	EnvMigrationFolder    = "GT_MIGRATION_FOLDER"
+	EnvMigrationFile      = "GT_MIGRATION_FILE"
+	EnvMigrationVersion   = "GT_MIGRATION_VERSION"
+	EnvMigrationRevision  = "GT_MIGRATION_REVISION"
+	EnvMigrationDirection = "GT_MIGRATION_DIRECTION"
+	EnvMigrationBatch     = "GT_MIGRATION_BATCH"
+	EnvNeo4jURI           = "NEO4J_URI"
 )
 
 // IsCypher returns true if current step is Cypher. But does not check if cypher really contains something.
@@ -49,21 +77,88 @@ func (s ExecutionStep) Command() []string {
 	return s.command
 }
 
+// Migration returns context of the migration file, which produced current step.
+// Returns nil if the step was not created from migration file.
+func (s ExecutionStep) Migration() *MigrationContext {
+	return s.migration
+}
+
+// isNoop returns true for steps, which does not need to be executed.
+// That is 'exit' command and Cypher with comments only.
+func (s ExecutionStep) isNoop() bool {
+	if !s.IsCypher() {
+		return s.command[0] == "exit"
+	}
+	for line := range bytes.Lines(s.cypher.Bytes()) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && !bytes.HasPrefix(line, []byte("//")) {
+			return false
+		}
+	}
+	return true
+}
+
+// Direction returns 'up' or 'down' based on the migration file.
+func (mc *MigrationContext) Direction() string {
+	if mc.IsDowngrade {
+		return "down"
+	}
+	return "up"
+}
+
+// Env returns environment variables in form KEY=value describing the migration.
+// Returns nil on nil MigrationContext, so it is safe to call on any step.
+func (mc *MigrationContext) Env() []string {
+	if mc == nil {
+		return nil
+	}
+	env := []string{
+		EnvMigrationFolder + "=" + mc.FolderName,
+		EnvMigrationFile + "=" + mc.Path,
+		EnvMigrationRevision + "=" + strconv.FormatInt(mc.Revision, 10),
+		EnvMigrationDirection + "=" + mc.Direction(),
+	}
+	if mc.Version != nil {
+		env = append(env, EnvMigrationVersion+"="+mc.Version.String())
+	}
+	if mc.Database != "" {
+		// Overrides database from the connection, the later value wins.
+		env = append(env, EnvNeo4jDatabase+"="+mc.Database)
+	}
+	return env
+}
+
 // IsEmpty checks if there are steps to do.
 func (e ExecutionSteps) IsEmpty() bool {
 	return len(e) == 0
 }
 
+// HasDowngrade checks if any step rolls back a migration.
+func (e ExecutionSteps) HasDowngrade() bool {
+	for _, step := range e {
+		if step.migration != nil && step.migration.IsDowngrade {
+			return true
+		}
+	}
+	return false
+}
+
 // AddCypher adds all Cyphers into one buffer. If current step is Cypher as well, it is reused.
 // Otherwise new buffer is created.
 func (e *ExecutionSteps) AddCypher(cypher ...string) {
+	e.AddMigrationCypher(nil, cypher...)
+}
+
+// AddMigrationCypher adds all Cyphers into one buffer together with context of migration file.
+// If current step is Cypher of the same migration file, it is reused. Otherwise new buffer is created.
+func (e *ExecutionSteps) AddMigrationCypher(mc *MigrationContext, cypher ...string) {
 	if len(cypher) == 0 {
 		return
 	}
 
 	l := len(*e)
-	// There are some entries, and the last one is buffer
-	if l > 0 && (*e)[l-1].IsCypher() {
+	// There are some entries, and the last one is buffer of the same migration
+	if l > 0 && (*e)[l-1].IsCypher() && (*e)[l-1].migration == mc {
 		buf := (*e)[l-1].cypher
 		for _, c := range cypher {
 			_, _ = buf.WriteString(c)
@@ -76,18 +171,25 @@ func (e *ExecutionSteps) AddCypher(cypher ...string) {
 		_, _ = buf.WriteString(cypher[i])
 	}
 	*e = append(*e, ExecutionStep{
-		cypher: buf,
+		cypher:    buf,
+		migration: mc,
 	})
 }
 
 // AddCommand adds command with parameters to step list.
 func (e *ExecutionSteps) AddCommand(args []string) {
+	e.AddMigrationCommand(args, nil)
+}
+
+// AddMigrationCommand adds command with parameters to step list together with context of migration file.
+func (e *ExecutionSteps) AddMigrationCommand(args []string, mc *MigrationContext) {
 	if len(args) == 0 {
 		return
 	}
 
 	*e = append(*e, ExecutionStep{
-		command: args,
+		command:   args,
+		migration: mc,
 	})
 }
 
@@ -120,7 +222,14 @@ func argsToString(args []string) string {
 }
 
 // CreateBuilder creates default Cypher builder.
+// Every migration file is placed into separate steps, so it can be executed and measured on its own,
+// see config.Planner.ProcessPerFile.
+// Bookkeeping node stores when the file started and finished and how long it took in milliseconds.
+//
+// When folders are mapped to multiple databases, or to other database than the connection one, every file starts
+// with :use command, so the plan can be also executed in single cypher-shell session.
 func (p *Planner) CreateBuilder(steps *ExecutionSteps, abs bool) Builder {
+	multiDatabase := len(p.foldersByDatabase()) > 1
 	return func(cf *MigrationFile, version *semver.Version) error {
 		header := "Importing"
 		switch {
@@ -134,70 +243,112 @@ func (p *Planner) CreateBuilder(steps *ExecutionSteps, abs bool) Builder {
 			header = "Downgrading"
 		}
 
-		steps.AddCypher(fmt.Sprintf(
+		fp := cf.Path
+		if abs {
+			var err error
+			if fp, err = filepath.Abs(cf.Path); err != nil {
+				return err
+			}
+		}
+		mc := &MigrationContext{
+			FolderName:  cf.FolderName,
+			Path:        fp,
+			Version:     version,
+			Revision:    cf.Timestamp,
+			IsDowngrade: cf.IsDowngrade,
+			IsSnapshot:  cf.IsSnapshot,
+		}
+		useDatabase := ""
+		if db := p.resolveDatabase(p.folderDatabase(cf.FolderName)); multiDatabase || db != p.connectionDatabase() {
+			mc.Database = db
+		}
+		// Empty name is default database of the session, which cannot be selected with :use.
+		if mc.Database != "" {
+			useDatabase = ":use " + mc.Database + "\n"
+		}
+
+		steps.AddMigrationCypher(mc, fmt.Sprintf(
 			"// %s folder %s - ver:%s\n",
 			header,
 			cf.FolderName,
 			(&TargetVersion{Version: version, Revision: cf.Timestamp}).String(),
 		))
 
-		if cf.FileType == Command { //nolint:nestif // TODO handle complexity
-			if err := p.addCommand(steps, cf); err != nil {
+		if cf.FileType == Command {
+			if err := p.addCommand(steps, cf, mc); err != nil {
 				return err
 			}
 		} else {
-			steps.AddCypher(":source ")
-			if abs {
-				fp, err := filepath.Abs(cf.Path)
-				if err != nil {
-					return err
-				}
-				steps.AddCypher(fp)
-			} else {
-				steps.AddCypher(cf.Path)
+			steps.AddMigrationCypher(mc, useDatabase)
+			if !cf.IsSnapshot {
+				steps.AddMigrationCypher(mc, startedAtParam)
 			}
-			steps.AddCypher(";\n")
+			steps.AddMigrationCypher(mc, ":source ", fp, ";\n")
 		}
 
 		// For snapshot do not store any extra version, as that should be already part of snapshot.
 		if cf.IsSnapshot {
-			steps.AddCypher("\n")
+			steps.AddMigrationCypher(mc, "\n")
 			return nil
 		}
 
-		var nodeLabels []string
-		if cf.FolderName == p.config.Planner.SchemaFolder.FolderName {
-			nodeLabels = p.config.Planner.SchemaFolder.NodeLabels
-		} else {
-			folder := p.config.Planner.Folders[cf.FolderName]
-			if folder != nil {
-				nodeLabels = folder.NodeLabels
-			}
-		}
-
+		nodeLabels := p.nodeLabels(cf.FolderName)
 		if len(nodeLabels) == 0 {
 			return fmt.Errorf("fail to import folder '%s', cannot determine DB labels", cf.FolderName)
 		}
-		steps.AddCypher(":param version => '", version.String(), "';\n")
-		steps.AddCypher(":param file => ", strconv.FormatInt(cf.Timestamp, 10), ";\n")
+		if cf.FileType == Command {
+			// Commands run outside of cypher-shell, so start time is replaced by Executor with the real one.
+			steps.AddMigrationCypher(mc, useDatabase, startedAtParam)
+		}
+		steps.AddMigrationCypher(mc, ":param version => '", version.String(), "';\n")
+		steps.AddMigrationCypher(mc, ":param file => ", strconv.FormatInt(cf.Timestamp, 10), ";\n")
 		if cf.IsDowngrade {
 			// Try to find version and then remove current file from files.
 			// Or delete whole node, when there are no more files left.
-			steps.AddCypher(
+			steps.AddMigrationCypher(mc,
 				`MATCH (sm:`, strings.Join(nodeLabels, ":"), ` {version: $version, file: $file}) `,
-				`SET sm.deleted_at = timestamp();`,
+				`SET sm.deleted_at = timestamp(), sm.down_started_at = $migration_started_at, `,
+				`sm.down_finished_at = timestamp(), sm.down_duration_ms = timestamp() - $migration_started_at;`,
 			)
 		} else {
 			// Match or create node by version and set files or add current file.
-			steps.AddCypher(
+			steps.AddMigrationCypher(mc,
 				`MERGE (sm:`, strings.Join(nodeLabels, ":"), ` {version: $version, file: $file}) `,
 				`ON CREATE SET sm.created_at = timestamp() `,
-				`SET sm.updated_at = timestamp(), sm.deleted_at = null;`,
+				`SET sm.updated_at = timestamp(), sm.deleted_at = null, sm.started_at = $migration_started_at, `,
+				`sm.finished_at = timestamp(), sm.duration_ms = timestamp() - $migration_started_at;`,
 			)
 		}
-		steps.AddCypher("\n\n")
+		steps.AddMigrationCypher(mc, "\n\n")
+		return nil
+	}
+}
+
+// AddDrop adds drop Cypher file from the import dir into the steps. It is used to wipe out the entire database.
+// When the batch uses multiple databases, drop file is executed in each of them.
+func (p *Planner) AddDrop(steps *ExecutionSteps, importDir string, batch Batch) error {
+	if p.config.Planner.DropCypherFile == "" {
+		return errors.New("drop cypher file is not specified")
+	}
+	fp, err := filepath.Abs(filepath.Join(importDir, p.config.Planner.DropCypherFile))
+	if err != nil {
+		return err
+	}
+	return p.addPerDatabase(steps, batch, func(_, use string) error {
+		steps.AddCypher(use, ":source ", fp, "\n")
 		return nil
+	})
+}
+
+// nodeLabels returns labels of bookkeeping node of given folder, or nil if folder is unknown.
+func (p *Planner) nodeLabels(folderName string) []string {
+	if folderName == p.config.Planner.SchemaFolder.FolderName {
+		return p.config.Planner.SchemaFolder.NodeLabels
+	}
+	if folder := p.config.Planner.Folders[folderName]; folder != nil {
+		return folder.NodeLabels
 	}
+	return nil
 }
 
 func parseArgs(line string) []string {
@@ -208,7 +359,7 @@ func parseArgs(line string) []string {
 	return args
 }
 
-func (p *Planner) addCommand(steps *ExecutionSteps, cf *MigrationFile) error {
+func (p *Planner) addCommand(steps *ExecutionSteps, cf *MigrationFile, mc *MigrationContext) error {
 	content, err := os.ReadFile(cf.Path)
 	if err != nil {
 		return err
@@ -227,7 +378,7 @@ func (p *Planner) addCommand(steps *ExecutionSteps, cf *MigrationFile) error {
 			// Add exit command when no other commands are added
 			if newCommands == 0 {
 				newCommands++
-				steps.AddCommand([]string{"exit"})
+				steps.AddMigrationCommand([]string{"exit"}, mc)
 			}
 			break
 		}
@@ -241,7 +392,7 @@ func (p *Planner) addCommand(steps *ExecutionSteps, cf *MigrationFile) error {
 		args[0] = fullPath
 
 		newCommands++
-		steps.AddCommand(args)
+		steps.AddMigrationCommand(args, mc)
 	}
 	if newCommands == 0 {
 		return fmt.Errorf("no commands to run in file %s, use 'exit' command to ignore file", cf.Path)
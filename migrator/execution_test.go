@@ -22,6 +22,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
 )
 
 var _ = Describe("ExecutionStep methods", func() {
@@ -92,6 +93,37 @@ var _ = Describe("ExecutionSteps", func() {
 		Expect(ess.String()).To(Equal("// Nothing to do in this file\n"))
 	})
 
+	It("Migration context is nil for plain steps", func() {
+		ess := migrator.ExecutionSteps{}
+		ess.AddCommand([]string{"my-cmd"})
+		ess.AddCypher("cypher;")
+
+		Expect(ess[0].Migration()).To(BeNil())
+		Expect(ess[0].Migration().Env()).To(BeNil())
+		Expect(ess[1].Migration()).To(BeNil())
+	})
+
+	It("Migration context is converted into env variables", func() {
+		ess := migrator.ExecutionSteps{}
+		ess.AddMigrationCommand([]string{"my-cmd"}, &migrator.MigrationContext{
+			FolderName:  "data",
+			Path:        "/import/data/v1.0.1/4800_down_cmd.run",
+			Version:     v101,
+			Revision:    4800,
+			IsDowngrade: true,
+		})
+
+		Expect(ess).To(HaveLen(1))
+		Expect(ess[0].Migration().Direction()).To(Equal("down"))
+		Expect(ess[0].Migration().Env()).To(ConsistOf(
+			"GT_MIGRATION_FOLDER=data",
+			"GT_MIGRATION_FILE=/import/data/v1.0.1/4800_down_cmd.run",
+			"GT_MIGRATION_VERSION=1.0.1",
+			"GT_MIGRATION_REVISION=4800",
+			"GT_MIGRATION_DIRECTION=down",
+		))
+	})
+
 	It("Printing out command with spaces works properly", func() {
 		var ess migrator.ExecutionSteps
 		Expect(ess.IsEmpty()).To(BeTrue())
@@ -194,6 +226,60 @@ var _ = Describe("Default Builder with testing data", func() {
 		))
 	})
 
+	It("Command steps carry context of migration file", func() {
+		buf := new(migrator.ExecutionSteps)
+		err := p.Plan(localFolders, migrator.DatabaseModel{
+			"schema": []migrator.DatabaseGraphVersion{
+				getDBGraphVersion(v100, 1000, 2000),
+			},
+		}, nil, "perf-seed", p.CreateBuilder(buf, false))
+		Expect(err).To(Succeed())
+
+		var commands []migrator.ExecutionStep
+		for _, step := range *buf {
+			if !step.IsCypher() {
+				commands = append(commands, step)
+			}
+		}
+		Expect(commands).To(HaveLen(3))
+		Expect(commands[0].Command()).To(Equal([]string{"/app/graph-tool", "abc", "-n", "456"}))
+		Expect(commands[0].Migration()).To(PointTo(MatchAllFields(Fields{
+			"FolderName":  Equal("data"),
+			"Path":        Equal("testdata/import/data/v1.0.1/4800_test_cmd.run"),
+			"Version":     Equal(v101),
+			"Revision":    BeEquivalentTo(4800),
+			"IsDowngrade": BeFalse(),
+			"IsSnapshot":  BeFalse(),
+			"Database":    BeEmpty(),
+		})))
+		// Both commands from the same file share the context
+		Expect(commands[1].Migration()).To(BeIdenticalTo(commands[0].Migration()))
+		Expect(commands[2].Command()).To(Equal([]string{"exit"}))
+		Expect(commands[2].Migration().FolderName).To(Equal("perf"))
+	})
+
+	It("Every migration file has its own steps", func() {
+		buf := new(migrator.ExecutionSteps)
+		err := p.Plan(localFolders, migrator.DatabaseModel{
+			"schema": []migrator.DatabaseGraphVersion{
+				getDBGraphVersion(v100, 1000, 2000),
+				getDBGraphVersion(v101, 1200, 1500),
+			},
+		}, &migrator.TargetVersion{Version: v100}, "seed", p.CreateBuilder(buf, false))
+		Expect(err).To(Succeed())
+
+		// data 1400 (cypher), schema 1500 (header, command, bookkeeping), schema 1200 (cypher)
+		Expect(*buf).To(HaveLen(5))
+		Expect((*buf)[0].Migration().Revision).To(BeEquivalentTo(1400))
+		Expect((*buf)[0].Cypher().String()).To(HavePrefix("// Importing folder data - ver:1.0.0+1400\n"))
+		Expect((*buf)[1].Migration().Revision).To(BeEquivalentTo(1500))
+		Expect((*buf)[2].Command()).To(HaveLen(4))
+		Expect((*buf)[3].Migration()).To(BeIdenticalTo((*buf)[1].Migration()))
+		Expect((*buf)[3].Cypher().String()).To(HavePrefix(":param migration_started_at => timestamp();\n"))
+		Expect((*buf)[4].Migration().Revision).To(BeEquivalentTo(1200))
+		Expect((*buf)[4].Migration().IsDowngrade).To(BeTrue())
+	})
+
 	It("Create Upgrade plan with snapshot and absolute path", func() {
 		buf := new(migrator.ExecutionSteps)
 		err := p.Plan(localFolders, nil, &migrator.TargetVersion{Version: v100}, "schema", p.CreateBuilder(buf, true))
@@ -256,6 +342,37 @@ var _ = Describe("Default Builder with testing data", func() {
 		Expect(plan).To(Equal(string(expectedContent)))
 	})
 
+	It("Protected batch refuses rollback and clean run without confirmation", func() {
+		steps := new(migrator.ExecutionSteps)
+		err := p.Plan(localFolders, migrator.DatabaseModel{
+			"schema": []migrator.DatabaseGraphVersion{getDBGraphVersion(v101, 1200, 1500)},
+		}, &migrator.TargetVersion{Version: v100}, "perf-seed", p.CreateBuilder(steps, false))
+		Expect(err).To(Succeed())
+		Expect(steps.HasDowngrade()).To(BeTrue())
+		Expect(p.CheckProtection("perf-seed", false, *steps, "")).To(Succeed())
+
+		plannerCfg.Planner.Batches["perf-seed"].Protected = true
+		plannerCfg.Planner.ConfirmToken = "staging"
+		Expect(p.IsProtected("perf-seed")).To(BeTrue())
+		Expect(p.IsProtected("seed")).To(BeFalse())
+
+		err = p.CheckProtection("perf-seed", false, *steps, "")
+		Expect(err).To(MatchError(migrator.ErrProtected))
+		Expect(err).To(MatchError("batch is protected, rollback of batch 'perf-seed' requires confirmation token"))
+		err = p.CheckProtection("perf-seed", true, nil, "invalid")
+		Expect(err).To(MatchError(
+			"batch is protected, invalid confirmation token for wiping out of batch 'perf-seed'"))
+		Expect(p.CheckProtection("perf-seed", false, *steps, "staging")).To(Succeed())
+		Expect(p.CheckProtection("perf-seed", false, nil, "")).To(Succeed())
+		Expect(p.CheckProtection("seed", false, *steps, "")).To(Succeed())
+		// Clean run of seed wipes out the database shared with protected perf-seed.
+		Expect(p.CheckProtection("seed", true, nil, "")).To(MatchError(
+			"batch is protected, wiping out of batch 'seed' requires confirmation token"))
+
+		plannerCfg.Planner.Protected = true
+		Expect(p.CheckProtection("seed", false, *steps, "")).To(MatchError(migrator.ErrProtected))
+	})
+
 	It("Create Upgrade+Downgrade plan", func() {
 		buf := new(migrator.ExecutionSteps)
 		err := p.Plan(localFolders, migrator.DatabaseModel{
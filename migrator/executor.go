@@ -0,0 +1,381 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+type (
+	// Process describes single process started by Executor.
+	Process struct {
+		Args   []string
+		Env    []string
+		Stdin  io.Reader
+		Stdout io.Writer
+		Stderr io.Writer
+	}
+
+	// ProcessRunner starts the process and blocks until it exits.
+	// Output of the process must be written into Stdout and Stderr of the Process.
+	ProcessRunner func(ctx context.Context, proc *Process) error
+
+	// AppliedChecker reports if the migration file is already recorded in DB, see Planner.IsApplied.
+	AppliedChecker func(ctx context.Context, mc *MigrationContext) (bool, error)
+
+	// RetryNotifier is called when failed step is going to be retried after the delay.
+	RetryNotifier func(step *StepReport, delay time.Duration)
+
+	// ProgressNotifier is called before every step with number of already processed steps and total number of steps.
+	// Migration context is nil for steps, which do not belong to any migration file, like drop.
+	ProgressNotifier func(done, total int, mc *MigrationContext)
+
+	// Executor runs execution steps one by one and records the output of each process into ExecutionReport.
+	// Cypher steps are executed with cypher-shell, other steps are executed as commands.
+	// Consecutive Cypher steps of migration files share one cypher-shell process,
+	// unless config.Planner.ProcessPerFile is set.
+	// Steps failed with transient Neo4j error are retried according to config.RetryPolicy.
+	Executor struct {
+		runner            ProcessRunner
+		cypherShellFormat string
+		processPerFile    bool
+		env               []string
+		retry             *config.RetryPolicy
+		applied           AppliedChecker
+		notifyRetry       RetryNotifier
+		notifyProgress    ProgressNotifier
+		interrupt         <-chan struct{}
+	}
+)
+
+// ErrInterrupted is returned by Executor, when execution was interrupted before all steps were executed.
+var ErrInterrupted = errors.New("execution interrupted")
+
+// NewExecutor creates Executor, which starts all processes with given runner.
+// If runner is nil, RunProcess is used. Env variables in form KEY=value are passed to all started processes.
+func (p *Planner) NewExecutor(runner ProcessRunner, env ...string) *Executor {
+	if runner == nil {
+		runner = RunProcess
+	}
+	retry := p.config.Planner.Retry
+	if retry == nil {
+		// Config was not normalized, so do not retry at all.
+		retry = &config.RetryPolicy{MaxAttempts: 1}
+	}
+	return &Executor{
+		runner:            runner,
+		cypherShellFormat: p.config.Planner.CypherShellFormat,
+		processPerFile:    p.config.Planner.ProcessPerFile,
+		env:               env,
+		retry:             retry,
+	}
+}
+
+// WithAppliedChecker sets checker, which is called before a step of migration file is retried.
+// If the file is already recorded as applied, the rest of the file is skipped instead of running it again.
+// When more files share the process, leading applied files are skipped. Without checker the failed step
+// is always retried as a whole.
+func (e *Executor) WithAppliedChecker(checker AppliedChecker) *Executor {
+	e.applied = checker
+	return e
+}
+
+// WithRetryNotifier sets function, which is called every time a failed step is going to be retried.
+func (e *Executor) WithRetryNotifier(notifier RetryNotifier) *Executor {
+	e.notifyRetry = notifier
+	return e
+}
+
+// WithProgressNotifier sets function, which is called before every executed step.
+func (e *Executor) WithProgressNotifier(notifier ProgressNotifier) *Executor {
+	e.notifyProgress = notifier
+	return e
+}
+
+// WithInterrupt sets channel, which interrupts execution when closed. Unlike canceled context,
+// the running step is not killed, but finished first. Then Execute returns ErrInterrupted.
+func (e *Executor) WithInterrupt(interrupt <-chan struct{}) *Executor {
+	e.interrupt = interrupt
+	return e
+}
+
+// RunProcess is default ProcessRunner, which executes the process as a child of the current process.
+func RunProcess(ctx context.Context, c *Process) error {
+	// #nosec G204
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	return cmd.Run()
+}
+
+// Execute runs all steps in order and stops on first failure.
+// Report is returned always, even when execution fails, and contains all steps executed so far.
+func (e *Executor) Execute(ctx context.Context, steps ExecutionSteps) (*ExecutionReport, error) {
+	report := &ExecutionReport{StartedAt: time.Now()}
+
+	groups := e.group(steps)
+	total := 0
+	// Index of the group with the last step of every migration file, the file is applied when the group succeeds.
+	lastGroup := map[*MigrationContext]int{}
+	for i, group := range groups {
+		total += len(group)
+		for _, step := range group {
+			if mc := step.Migration(); mc != nil {
+				lastGroup[mc] = i
+			}
+		}
+	}
+
+	ctx, span := tracer().Start(ctx, "Executor.Execute", trace.WithAttributes(attrSteps.Int(total)))
+	var err error
+	defer func() { endSpan(span, err) }()
+	// Migration file, which was found as applied during retry, and its remaining steps must be skipped.
+	var appliedMigration *MigrationContext
+	done := 0
+	for i, group := range groups {
+		processed := done
+		done += len(group)
+		if appliedMigration != nil {
+			group = slices.DeleteFunc(slices.Clone(group), func(s ExecutionStep) bool {
+				return s.Migration() == appliedMigration
+			})
+			if len(group) == 0 {
+				continue
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if e.interrupted() {
+			err = ErrInterrupted
+			break
+		}
+		if e.notifyProgress != nil {
+			e.notifyProgress(processed, total, group[0].Migration())
+		}
+
+		var applied *MigrationContext
+		applied, err = e.executeWithRetry(ctx, report, group)
+		if applied != nil {
+			appliedMigration = applied
+		}
+		if err != nil {
+			break
+		}
+		for _, step := range group {
+			if mc := step.Migration(); mc != nil && lastGroup[mc] == i {
+				report.addApplied(mc)
+			}
+		}
+	}
+
+	// Step killed by canceled context after the interruption, because it took too long, is interrupted as well.
+	if err != nil && ctx.Err() != nil && e.interrupted() && !errors.Is(err, ErrInterrupted) {
+		err = fmt.Errorf("%w; %v", ErrInterrupted, err)
+	}
+	report.finish(err)
+	return report, err
+}
+
+// group splits steps, which are not noop, into groups executed by one process.
+// Steps without migration context, like wipe out, are never joined with other steps.
+func (e *Executor) group(steps ExecutionSteps) [][]ExecutionStep {
+	var groups [][]ExecutionStep
+	for _, step := range steps {
+		if step.isNoop() {
+			continue
+		}
+		if l := len(groups); l > 0 && e.canJoin(groups[l-1][len(groups[l-1])-1], step) {
+			groups[l-1] = append(groups[l-1], step)
+			continue
+		}
+		groups = append(groups, []ExecutionStep{step})
+	}
+	return groups
+}
+
+// canJoin checks if next step can run in the same cypher-shell process as the previous one.
+// Step in the default database cannot follow step, which selected another database with :use command.
+func (e *Executor) canJoin(prev, next ExecutionStep) bool {
+	if e.processPerFile || !prev.IsCypher() || !next.IsCypher() || prev.Migration() == nil || next.Migration() == nil {
+		return false
+	}
+	return next.Migration().Database != "" || prev.Migration().Database == ""
+}
+
+// executeWithRetry runs the group of steps and retries it, when it fails with retryable error.
+// Error might be reported even after the transaction with bookkeeping was committed, for example when connection
+// is lost during leader switch. So before every retry it is checked, that migration files are not recorded already.
+// Leading recorded files are skipped and the last of them is returned, so its remaining steps can be skipped too.
+func (e *Executor) executeWithRetry(
+	ctx context.Context,
+	report *ExecutionReport,
+	group []ExecutionStep,
+) (*MigrationContext, error) {
+	var applied *MigrationContext
+	delay := e.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		stepReport := e.executeStep(ctx, group, report.fileStartedAt(group[0].Migration()), attempt)
+		report.addStep(stepReport)
+		if stepReport.err == nil {
+			return applied, nil
+		}
+		if attempt >= e.retry.MaxAttempts || ctx.Err() != nil || !e.isRetryable(stepReport) {
+			return applied, stepReport.err
+		}
+
+		if e.notifyRetry != nil {
+			e.notifyRetry(stepReport, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return applied, ctx.Err()
+		case <-e.interrupt:
+			timer.Stop()
+			return applied, fmt.Errorf("%w; %v", ErrInterrupted, stepReport.err)
+		case <-timer.C:
+		}
+		delay = min(delay*2, e.retry.MaxBackoff)
+
+		if e.applied == nil {
+			continue
+		}
+		for len(group) > 0 && group[0].Migration() != nil {
+			mc := group[0].Migration()
+			isApplied, err := e.applied(ctx, mc)
+			if err != nil {
+				return applied, fmt.Errorf("%w; cannot check if migration was applied: %v", stepReport.err, err)
+			}
+			if !isApplied {
+				break
+			}
+			applied = mc
+			report.addApplied(mc)
+			for len(group) > 0 && group[0].Migration() == mc {
+				group = group[1:]
+			}
+		}
+		if len(group) == 0 {
+			return applied, nil
+		}
+	}
+}
+
+// interrupted checks if interrupt channel is closed. Nil channel is never closed.
+func (e *Executor) interrupted() bool {
+	select {
+	case <-e.interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryable checks if output or error of the step contains any of retryable Neo4j error codes.
+func (e *Executor) isRetryable(step *StepReport) bool {
+	for _, code := range e.retry.RetryableCodes {
+		if strings.Contains(step.Stderr, code) || strings.Contains(step.Stdout, code) ||
+			strings.Contains(step.Error, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// executeStep runs group of steps in single process. If fileStartedAt is not zero, other step of the migration file
+// of the first step was executed before and start time in its bookkeeping Cypher is replaced with it.
+// When the group contains multiple migration files, migration context is not set.
+func (e *Executor) executeStep(
+	ctx context.Context,
+	group []ExecutionStep,
+	fileStartedAt time.Time,
+	attempt int,
+) *StepReport {
+	step := group[0]
+	for _, s := range group[1:] {
+		if s.Migration() != step.Migration() {
+			step = ExecutionStep{cypher: step.cypher}
+			break
+		}
+	}
+	ctx, span := tracer().Start(ctx, "Executor.Step",
+		trace.WithAttributes(append(stepAttributes(step), attrAttempt.Int(attempt))...))
+
+	var stdout, stderr outputBuffer
+	proc := &Process{
+		Env:    slices.Concat(e.env, step.Migration().Env()),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	stepReport := &StepReport{Migration: step.Migration(), Attempt: attempt}
+
+	if step.IsCypher() {
+		proc.Args = []string{"cypher-shell", "--fail-fast", "--format", e.cypherShellFormat}
+		var input []byte
+		for i, s := range group {
+			cypher := s.Cypher().Bytes()
+			if i == 0 && !fileStartedAt.IsZero() {
+				cypher = bytes.Replace(cypher, []byte(startedAtParam),
+					fmt.Appendf(nil, ":param migration_started_at => %d;\n", fileStartedAt.UnixMilli()), 1)
+			}
+			input = append(input, cypher...)
+		}
+		// Use new reader every time, so the buffers of the steps are not consumed.
+		proc.Stdin = bytes.NewReader(input)
+		stepReport.Input = string(input)
+	} else {
+		proc.Args = step.Command()
+	}
+	stepReport.Args = proc.Args
+
+	stepReport.StartedAt = time.Now()
+	err := e.runner(ctx, proc)
+	stepReport.FinishedAt = time.Now()
+	stepReport.Duration = stepReport.FinishedAt.Sub(stepReport.StartedAt)
+	stepReport.Stdout = stdout.String()
+	stepReport.Stderr = stderr.String()
+
+	if err != nil {
+		stepReport.err = err
+		stepReport.Error = err.Error()
+		stepReport.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stepReport.ExitCode = exitErr.ExitCode()
+		}
+	}
+	span.SetAttributes(attrExitCode.Int(stepReport.ExitCode))
+	endSpan(span, err)
+	return stepReport
+}
@@ -0,0 +1,510 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Executor", func() {
+	var (
+		p     *migrator.Planner
+		steps migrator.ExecutionSteps
+		// executed keeps all processes passed to runner with stdin read into string.
+		executed []*migrator.Process
+		stdins   []string
+	)
+
+	recordingRunner := func(failOn string) migrator.ProcessRunner {
+		return func(_ context.Context, proc *migrator.Process) error {
+			executed = append(executed, proc)
+			in := ""
+			if proc.Stdin != nil {
+				b, err := io.ReadAll(proc.Stdin)
+				Expect(err).To(Succeed())
+				in = string(b)
+			}
+			stdins = append(stdins, in)
+			_, _ = io.WriteString(proc.Stdout, "out of "+proc.Args[0]+"\n")
+			if proc.Args[0] == failOn {
+				_, _ = io.WriteString(proc.Stderr, "something went wrong\n")
+				return errors.New("failed " + failOn)
+			}
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		executed, stdins = nil, nil
+		cfg := &config.Config{Planner: &config.Planner{
+			BaseFolder:        "import",
+			CypherShellFormat: "plain",
+			SchemaFolder:      &config.SchemaFolder{FolderName: "schema", MigrationType: "change"},
+		}}
+		Expect(cfg.Normalize()).To(Succeed())
+		var err error
+		p, err = migrator.NewPlanner(cfg)
+		Expect(err).To(Succeed())
+
+		steps = migrator.ExecutionSteps{}
+		steps.AddCypher("RETURN 1;\n")
+		steps.AddMigrationCommand([]string{"exit"}, nil)
+		steps.AddMigrationCommand([]string{"my-cmd", "arg"}, &migrator.MigrationContext{
+			FolderName: "data", Path: "data/v1.0.0/10_cmd.run", Version: v100, Revision: 10,
+		})
+		steps.AddCypher("RETURN 2;\n")
+	})
+
+	It("Executes all steps and records the report", func() {
+		report, err := p.NewExecutor(recordingRunner(""), "GT_MIGRATION_BATCH=seed").
+			Execute(context.Background(), steps)
+		Expect(err).To(Succeed())
+
+		Expect(executed).To(HaveLen(3))
+		Expect(executed[0].Args).To(Equal([]string{"cypher-shell", "--fail-fast", "--format", "plain"}))
+		Expect(stdins[0]).To(Equal("RETURN 1;\n"))
+		Expect(executed[0].Env).To(Equal([]string{"GT_MIGRATION_BATCH=seed"}))
+		Expect(executed[1].Args).To(Equal([]string{"my-cmd", "arg"}))
+		Expect(executed[1].Env).To(ContainElements(
+			"GT_MIGRATION_BATCH=seed", "GT_MIGRATION_FOLDER=data", "GT_MIGRATION_REVISION=10"))
+		Expect(stdins[2]).To(Equal("RETURN 2;\n"))
+
+		// Buffer of the step is not consumed
+		Expect(steps[0].Cypher().String()).To(Equal("RETURN 1;\n"))
+
+		Expect(report.Failed()).To(BeFalse())
+		Expect(report.Steps).To(HaveLen(3))
+		Expect(report.Steps[0].Input).To(Equal("RETURN 1;\n"))
+		Expect(report.Steps[0].Stdout).To(Equal("out of cypher-shell\n"))
+		Expect(report.Steps[1].Migration.FolderName).To(Equal("data"))
+		Expect(report.Steps[1].Stdout).To(Equal("out of my-cmd\n"))
+		for _, s := range report.Steps {
+			Expect(s.ExitCode).To(BeZero())
+			Expect(s.FinishedAt).NotTo(BeTemporally("<", s.StartedAt))
+		}
+	})
+
+	It("Stops on first failure and keeps the report", func() {
+		report, err := p.NewExecutor(recordingRunner("my-cmd")).Execute(context.Background(), steps)
+		Expect(err).To(MatchError("failed my-cmd"))
+
+		Expect(executed).To(HaveLen(2))
+		Expect(report.Failed()).To(BeTrue())
+		Expect(report.Error).To(Equal("failed my-cmd"))
+		Expect(report.Steps).To(HaveLen(2))
+		Expect(report.Steps[1].Stderr).To(Equal("something went wrong\n"))
+		Expect(report.Steps[1].Error).To(Equal("failed my-cmd"))
+		Expect(report.Steps[1].ExitCode).To(Equal(-1))
+		Expect(report.Applied).To(BeEmpty())
+	})
+
+	It("Reports progress before every step", func() {
+		var progress []string
+		_, err := p.NewExecutor(recordingRunner("")).
+			WithProgressNotifier(func(done, total int, mc *migrator.MigrationContext) {
+				path := ""
+				if mc != nil {
+					path = mc.Path
+				}
+				progress = append(progress, strconv.Itoa(done)+"/"+strconv.Itoa(total)+" "+path)
+			}).
+			Execute(context.Background(), steps)
+		Expect(err).To(Succeed())
+		Expect(progress).To(Equal([]string{"0/3 ", "1/3 data/v1.0.0/10_cmd.run", "2/3 "}))
+	})
+
+	It("Creates span for every executed step", func() {
+		recorder := tracetest.NewSpanRecorder()
+		previous := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		DeferCleanup(otel.SetTracerProvider, previous)
+
+		_, err := p.NewExecutor(recordingRunner("my-cmd")).Execute(context.Background(), steps)
+		Expect(err).To(MatchError("failed my-cmd"))
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(3))
+		Expect(spans[0].Name()).To(Equal("Executor.Step"))
+		Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+		Expect(spans[0].Parent().SpanID()).To(Equal(spans[2].SpanContext().SpanID()))
+
+		Expect(spans[1].Name()).To(Equal("Executor.Step"))
+		Expect(spans[1].Status().Code).To(Equal(codes.Error))
+		Expect(spans[1].Attributes()).To(ContainElements(
+			attribute.String("graph_tool.folder", "data"),
+			attribute.String("graph_tool.version", "1.0.0"),
+			attribute.Int64("graph_tool.revision", 10),
+			attribute.String("graph_tool.file_type", "command"),
+			attribute.Int("graph_tool.attempt", 1),
+			attribute.Int("graph_tool.exit_code", -1),
+		))
+
+		Expect(spans[2].Name()).To(Equal("Executor.Execute"))
+		Expect(spans[2].Status().Code).To(Equal(codes.Error))
+		Expect(spans[2].Attributes()).To(ContainElement(attribute.Int("graph_tool.steps", 3)))
+	})
+
+	It("Does not start anything when context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		report, err := p.NewExecutor(recordingRunner("")).Execute(ctx, steps)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(executed).To(BeEmpty())
+		Expect(report.Steps).To(BeEmpty())
+	})
+
+	It("Finishes running step when interrupted", func() {
+		interrupt := make(chan struct{})
+		runner := recordingRunner("")
+		report, err := p.NewExecutor(func(ctx context.Context, proc *migrator.Process) error {
+			close(interrupt)
+			return runner(ctx, proc)
+		}).WithInterrupt(interrupt).Execute(context.Background(), steps)
+		Expect(err).To(MatchError(migrator.ErrInterrupted))
+
+		Expect(executed).To(HaveLen(1))
+		Expect(report.Steps).To(HaveLen(1))
+		Expect(report.Steps[0].Error).To(BeEmpty())
+		Expect(report.Interrupted).To(BeTrue())
+		Expect(report.Error).To(Equal("execution interrupted"))
+		Expect(report.Applied).To(BeEmpty())
+	})
+
+	It("Reports step killed after interruption as interrupted", func() {
+		interrupt := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		report, err := p.NewExecutor(func(ctx context.Context, _ *migrator.Process) error {
+			close(interrupt)
+			cancel()
+			return ctx.Err()
+		}).WithInterrupt(interrupt).Execute(ctx, steps)
+		Expect(err).To(MatchError(migrator.ErrInterrupted))
+		Expect(err).To(MatchError(ContainSubstring("context canceled")))
+
+		Expect(report.Steps).To(HaveLen(1))
+		Expect(report.Interrupted).To(BeTrue())
+		Expect(report.Error).To(Equal("execution interrupted; context canceled"))
+	})
+
+	It("Reports files applied before interruption", func() {
+		interrupt := make(chan struct{})
+		runner := recordingRunner("")
+		report, err := p.NewExecutor(func(ctx context.Context, proc *migrator.Process) error {
+			if proc.Args[0] == "my-cmd" {
+				close(interrupt)
+			}
+			return runner(ctx, proc)
+		}).WithInterrupt(interrupt).Execute(context.Background(), steps)
+		Expect(err).To(MatchError(migrator.ErrInterrupted))
+
+		Expect(executed).To(HaveLen(2))
+		Expect(report.Applied).To(ConsistOf(BeIdenticalTo(steps[2].Migration())))
+	})
+
+	It("Measures migration files and replaces start time of commands", func() {
+		mc := &migrator.MigrationContext{
+			FolderName: "data", Path: "data/v1.0.0/10_cmd.run", Version: v100, Revision: 10,
+		}
+		cmdSteps := migrator.ExecutionSteps{}
+		cmdSteps.AddMigrationCypher(mc, "// Running command from folder data - ver:1.0.0+10\n")
+		cmdSteps.AddMigrationCommand([]string{"my-cmd"}, mc)
+		cmdSteps.AddMigrationCypher(mc, ":param migration_started_at => timestamp();\n", "MERGE (sm:DataVersion);\n")
+		cmdSteps.AddMigrationCypher(nil, "// Only comment is not executed\n\n")
+
+		report, err := p.NewExecutor(recordingRunner("")).Execute(context.Background(), cmdSteps)
+		Expect(err).To(Succeed())
+
+		Expect(executed).To(HaveLen(2))
+		Expect(executed[0].Args).To(Equal([]string{"my-cmd"}))
+		Expect(stdins[1]).To(MatchRegexp(`^:param migration_started_at => \d+;\nMERGE \(sm:DataVersion\);\n$`))
+		Expect(stdins[1]).To(ContainSubstring(strconv.FormatInt(report.Steps[0].StartedAt.UnixMilli(), 10)))
+
+		Expect(report.Files).To(HaveLen(1))
+		Expect(report.Files[0].Migration).To(BeIdenticalTo(mc))
+		Expect(report.Files[0].StartedAt).To(Equal(report.Steps[0].StartedAt))
+		Expect(report.Files[0].FinishedAt).To(Equal(report.Steps[1].FinishedAt))
+		Expect(report.Files[0].Duration).To(Equal(report.Files[0].FinishedAt.Sub(report.Files[0].StartedAt)))
+		Expect(report.Applied).To(ConsistOf(BeIdenticalTo(mc)))
+	})
+
+	Describe("Shared process", func() {
+		var fileSteps migrator.ExecutionSteps
+
+		BeforeEach(func() {
+			fileSteps = migrator.ExecutionSteps{}
+			fileSteps.AddCypher("// wipe out the entire database\n", "MATCH (n) DETACH DELETE n;\n")
+			for i, db := range []string{"", "", "audit", "", "audit"} {
+				fileSteps.AddMigrationCypher(&migrator.MigrationContext{
+					FolderName: "data", Version: v100, Revision: int64(i + 1), Database: db,
+				}, "FILE ", strconv.Itoa(i+1), ";\n")
+			}
+		})
+
+		It("Joins consecutive migration files into one process", func() {
+			report, err := p.NewExecutor(recordingRunner("")).Execute(context.Background(), fileSteps)
+			Expect(err).To(Succeed())
+
+			Expect(stdins).To(Equal([]string{
+				"// wipe out the entire database\nMATCH (n) DETACH DELETE n;\n",
+				"FILE 1;\nFILE 2;\nFILE 3;\n",
+				"FILE 4;\nFILE 5;\n",
+			}))
+			Expect(report.Steps).To(HaveLen(3))
+			Expect(report.Steps[1].Migration).To(BeNil())
+			Expect(report.Steps[1].Input).To(Equal(stdins[1]))
+			Expect(report.Applied).To(HaveLen(5))
+		})
+
+		It("Runs every migration file in own process, when configured", func() {
+			cfg := &config.Config{Planner: &config.Planner{
+				BaseFolder:     "import",
+				SchemaFolder:   &config.SchemaFolder{FolderName: "schema", MigrationType: "change"},
+				ProcessPerFile: true,
+			}}
+			Expect(cfg.Normalize()).To(Succeed())
+			var err error
+			p, err = migrator.NewPlanner(cfg)
+			Expect(err).To(Succeed())
+
+			report, err := p.NewExecutor(recordingRunner("")).Execute(context.Background(), fileSteps)
+			Expect(err).To(Succeed())
+			Expect(stdins).To(HaveLen(6))
+			Expect(stdins[1]).To(Equal("FILE 1;\n"))
+			Expect(report.Files).To(HaveLen(5))
+			Expect(report.Files[0].Migration.Revision).To(BeEquivalentTo(1))
+		})
+	})
+
+	It("Returns slowest migration files", func() {
+		report := &migrator.ExecutionReport{Files: []*migrator.FileReport{
+			{Migration: &migrator.MigrationContext{Revision: 1}, Duration: time.Second},
+			{Migration: &migrator.MigrationContext{Revision: 2}, Duration: time.Minute},
+			{Migration: &migrator.MigrationContext{Revision: 3}, Duration: time.Millisecond},
+		}}
+
+		slowest := report.Slowest(2)
+		Expect(slowest).To(HaveLen(2))
+		Expect(slowest[0].Migration.Revision).To(BeEquivalentTo(2))
+		Expect(slowest[1].Migration.Revision).To(BeEquivalentTo(1))
+		Expect(report.Slowest(10)).To(HaveLen(3))
+		Expect(report.Files[0].Migration.Revision).To(BeEquivalentTo(1), "original order is kept")
+	})
+
+	Describe("Retry", func() {
+		var failures map[string]int
+
+		// failingRunner fails every command given number of times with output of cypher-shell.
+		failingRunner := func(stderr string) migrator.ProcessRunner {
+			record := recordingRunner("")
+			return func(ctx context.Context, proc *migrator.Process) error {
+				if failures[proc.Args[0]] > 0 {
+					failures[proc.Args[0]]--
+					executed = append(executed, proc)
+					stdins = append(stdins, "")
+					_, _ = io.WriteString(proc.Stderr, stderr)
+					return errors.New("exit status 1")
+				}
+				return record(ctx, proc)
+			}
+		}
+
+		BeforeEach(func() {
+			failures = map[string]int{}
+			cfg := &config.Config{Planner: &config.Planner{
+				BaseFolder:   "import",
+				SchemaFolder: &config.SchemaFolder{FolderName: "schema", MigrationType: "change"},
+				Retry: &config.RetryPolicy{
+					MaxAttempts:    3,
+					InitialBackoff: time.Millisecond,
+					MaxBackoff:     2 * time.Millisecond,
+				},
+			}}
+			Expect(cfg.Normalize()).To(Succeed())
+			var err error
+			p, err = migrator.NewPlanner(cfg)
+			Expect(err).To(Succeed())
+		})
+
+		It("Retries step failed with transient error", func() {
+			failures["my-cmd"] = 2
+			var delays []time.Duration
+			report, err := p.NewExecutor(failingRunner("Neo.TransientError.Transaction.DeadlockDetected\n")).
+				WithRetryNotifier(func(_ *migrator.StepReport, delay time.Duration) { delays = append(delays, delay) }).
+				Execute(context.Background(), steps)
+			Expect(err).To(Succeed())
+
+			Expect(executed).To(HaveLen(5))
+			Expect(delays).To(Equal([]time.Duration{time.Millisecond, 2 * time.Millisecond}))
+			Expect(report.Failed()).To(BeFalse())
+			Expect(report.Steps).To(HaveLen(5))
+			Expect(report.Steps[1].Attempt).To(Equal(1))
+			Expect(report.Steps[1].Error).To(Equal("exit status 1"))
+			Expect(report.Steps[3].Attempt).To(Equal(3))
+			Expect(report.Steps[3].Error).To(BeEmpty())
+			Expect(report.Files).To(HaveLen(1))
+		})
+
+		It("Does not retry when interrupted", func() {
+			failures["my-cmd"] = 2
+			interrupt := make(chan struct{})
+			report, err := p.NewExecutor(failingRunner("Neo.TransientError.Transaction.DeadlockDetected\n")).
+				WithRetryNotifier(func(*migrator.StepReport, time.Duration) { close(interrupt) }).
+				WithInterrupt(interrupt).
+				Execute(context.Background(), steps)
+			Expect(err).To(MatchError(migrator.ErrInterrupted))
+			Expect(err).To(MatchError(ContainSubstring("exit status 1")))
+			Expect(executed).To(HaveLen(2))
+			Expect(report.Interrupted).To(BeTrue())
+		})
+
+		It("Gives up after max attempts", func() {
+			failures["my-cmd"] = 5
+			report, err := p.NewExecutor(failingRunner("Neo.ClientError.Cluster.NotALeader\n")).
+				Execute(context.Background(), steps)
+			Expect(err).To(MatchError("exit status 1"))
+			Expect(executed).To(HaveLen(4))
+			Expect(report.Steps[3].Attempt).To(Equal(3))
+		})
+
+		It("Does not retry other errors", func() {
+			failures["my-cmd"] = 1
+			_, err := p.NewExecutor(failingRunner("Neo.ClientError.Statement.SyntaxError\n")).
+				Execute(context.Background(), steps)
+			Expect(err).To(MatchError("exit status 1"))
+			Expect(executed).To(HaveLen(2))
+		})
+
+		It("Skips rest of migration file already recorded in DB", func() {
+			mc := &migrator.MigrationContext{
+				FolderName: "data", Path: "data/v1.0.0/10_cmd.run", Version: v100, Revision: 10,
+			}
+			cmdSteps := migrator.ExecutionSteps{}
+			cmdSteps.AddMigrationCommand([]string{"my-cmd"}, mc)
+			cmdSteps.AddMigrationCypher(mc, "MERGE (sm:DataVersion);\n")
+			cmdSteps.AddCypher("RETURN 1;\n")
+
+			failures["my-cmd"] = 1
+			var checked []*migrator.MigrationContext
+			report, err := p.NewExecutor(failingRunner("Neo.TransientError.General.DatabaseUnavailable\n")).
+				WithAppliedChecker(func(_ context.Context, checkMc *migrator.MigrationContext) (bool, error) {
+					checked = append(checked, checkMc)
+					return true, nil
+				}).
+				Execute(context.Background(), cmdSteps)
+			Expect(err).To(Succeed())
+
+			Expect(checked).To(ConsistOf(BeIdenticalTo(mc)))
+			Expect(executed).To(HaveLen(2))
+			Expect(stdins[1]).To(Equal("RETURN 1;\n"))
+			Expect(report.Failed()).To(BeFalse())
+		})
+
+		It("Skips leading files of shared process already recorded in DB", func() {
+			fileSteps := migrator.ExecutionSteps{}
+			for i := 1; i <= 3; i++ {
+				fileSteps.AddMigrationCypher(&migrator.MigrationContext{
+					FolderName: "data", Version: v100, Revision: int64(i),
+				}, "FILE ", strconv.Itoa(i), ";\n")
+			}
+
+			failures["cypher-shell"] = 1
+			report, err := p.NewExecutor(failingRunner("Neo.TransientError.General.DatabaseUnavailable\n")).
+				WithAppliedChecker(func(_ context.Context, mc *migrator.MigrationContext) (bool, error) {
+					return mc.Revision == 1, nil
+				}).
+				Execute(context.Background(), fileSteps)
+			Expect(err).To(Succeed())
+
+			Expect(executed).To(HaveLen(2))
+			Expect(stdins[1]).To(Equal("FILE 2;\nFILE 3;\n"))
+			Expect(report.Steps).To(HaveLen(2))
+			Expect(report.Applied).To(HaveLen(3))
+			Expect(report.Applied[0].Revision).To(BeEquivalentTo(1))
+		})
+
+		It("Fails when applied check fails", func() {
+			failures["my-cmd"] = 1
+			_, err := p.NewExecutor(failingRunner("Neo.TransientError.General.DatabaseUnavailable\n")).
+				WithAppliedChecker(func(context.Context, *migrator.MigrationContext) (bool, error) {
+					return false, errors.New("no leader")
+				}).
+				Execute(context.Background(), steps)
+			Expect(err).To(MatchError("exit status 1; cannot check if migration was applied: no leader"))
+			Expect(executed).To(HaveLen(2))
+		})
+	})
+
+	It("Keeps only tail of long output", func() {
+		runner := func(_ context.Context, proc *migrator.Process) error {
+			_, _ = io.WriteString(proc.Stdout, strings.Repeat("a", 70*1024))
+			_, _ = io.WriteString(proc.Stdout, "the end")
+			return nil
+		}
+		report, err := p.NewExecutor(runner).Execute(context.Background(), steps[:1])
+		Expect(err).To(Succeed())
+		Expect(report.Steps[0].Stdout).To(HavePrefix("... output truncated ...\n"))
+		Expect(report.Steps[0].Stdout).To(HaveSuffix("the end"))
+		Expect(len(report.Steps[0].Stdout)).To(BeNumerically("<", 65*1024))
+	})
+
+	It("RunProcess captures output and exit code", func() {
+		cmdSteps := migrator.ExecutionSteps{}
+		cmdSteps.AddCommand([]string{"sh", "-c", "echo $MY_VAR; echo bad >&2; exit 3"})
+
+		report, err := p.NewExecutor(nil, "MY_VAR=hello").Execute(context.Background(), cmdSteps)
+		Expect(err).To(HaveOccurred())
+		Expect(report.Steps).To(HaveLen(1))
+		Expect(report.Steps[0].Stdout).To(Equal("hello\n"))
+		Expect(report.Steps[0].Stderr).To(Equal("bad\n"))
+		Expect(report.Steps[0].ExitCode).To(Equal(3))
+	})
+
+	It("Writes report as JSON", func() {
+		report, err := p.NewExecutor(recordingRunner("")).Execute(context.Background(), steps)
+		Expect(err).To(Succeed())
+
+		reportPath := filepath.Join(GinkgoT().TempDir(), "report.json")
+		Expect(report.WriteFile(reportPath)).To(Succeed())
+
+		content, err := os.ReadFile(reportPath)
+		Expect(err).To(Succeed())
+		var decoded map[string]any
+		Expect(json.Unmarshal(content, &decoded)).To(Succeed())
+		Expect(decoded).To(HaveKey("started_at"))
+		Expect(decoded["steps"]).To(HaveLen(3))
+		Expect(decoded["steps"].([]any)[1]).To(HaveKeyWithValue("migration", HaveKeyWithValue("version", "1.0.0")))
+	})
+})
@@ -15,12 +15,14 @@
 package migrator
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"regexp"
 
 	"github.com/Masterminds/semver/v3"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/indykite/neo4j-graph-tool-core/config"
 )
@@ -53,6 +55,24 @@ func (p *Planner) Plan(
 	batch Batch,
 	builder Builder,
 ) error {
+	return p.PlanContext(context.Background(), localFolders, dbModel, targetVersion, batch, builder)
+}
+
+// PlanContext is like Plan, but the context is used as parent of the tracing span.
+func (p *Planner) PlanContext(
+	ctx context.Context,
+	localFolders LocalFolders,
+	dbModel DatabaseModel,
+	targetVersion *TargetVersion,
+	batch Batch,
+	builder Builder,
+) (err error) {
+	_, span := tracer().Start(ctx, "Planner.Plan", trace.WithAttributes(
+		attrBatch.String(string(batch)),
+		attrTarget.String(targetVersion.String()),
+	))
+	defer func() { endSpan(span, err) }()
+
 	var batchFolders []string
 	if batch != "schema" {
 		// schema is implicit batch
@@ -71,6 +91,10 @@ func (p *Planner) Plan(
 
 	// preventSnapshot can disable using snapshots even they exists.
 	preventSnapshot := false // TODO: read from configuration
+	// Snapshot is executed in single database, so it cannot be used when batch is spread across more of them.
+	if len(p.Databases(batch)) > 1 {
+		preventSnapshot = true
+	}
 
 	localFolders.SortByVersion() // Sort by version first, so we iterate from oldest to newest
 
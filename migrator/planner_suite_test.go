@@ -86,13 +86,14 @@ func (ms *MockSession) ExecuteWrite(
 	return work(ms.tx)
 }
 
-func (*MockSession) Run(
-	_ context.Context,
-	_ string,
-	_ map[string]any,
+// Run uses the same transaction mock, so auto-commit queries are expected in the same way as managed ones.
+func (ms *MockSession) Run(
+	ctx context.Context,
+	cypher string,
+	params map[string]any,
 	_ ...func(*neo4j.TransactionConfig),
 ) (neo4j.Result, error) {
-	panic("Run is not supported")
+	return ms.tx.Run(ctx, cypher, params)
 }
 
 func (*MockSession) Close(_ context.Context) error {
@@ -0,0 +1,86 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrProtected is returned, when destructive operation on protected batch is not confirmed.
+var ErrProtected = errors.New("batch is protected")
+
+// IsProtected returns true, when all batches are protected by config.Planner.Protected,
+// or the given batch is protected by its config.
+func (p *Planner) IsProtected(batch Batch) bool {
+	if p.config.Planner.Protected {
+		return true
+	}
+	detail, ok := p.config.Planner.Batches[string(batch)]
+	return ok && detail.Protected
+}
+
+// CheckProtection returns error wrapping ErrProtected, when the batch is protected and clean run or steps
+// rolling back migrations are not confirmed with config.Planner.ConfirmToken.
+// Clean run wipes out whole databases, so it must be confirmed also when any of them is protected.
+func (p *Planner) CheckProtection(batch Batch, clean bool, steps ExecutionSteps, confirm string) error {
+	var operation string
+	switch {
+	case clean && (p.IsProtected(batch) || slices.ContainsFunc(p.Databases(batch), p.IsDatabaseProtected)):
+		operation = "wiping out"
+	case !clean && p.IsProtected(batch) && steps.HasDowngrade():
+		operation = "rollback"
+	default:
+		return nil
+	}
+	return p.checkConfirm(fmt.Sprintf("%s of batch '%s'", operation, batch), confirm)
+}
+
+// IsDatabaseProtected returns true, when all batches are protected, or any protected batch uses the database.
+// Empty name is the database from connection config.
+func (p *Planner) IsDatabaseProtected(database string) bool {
+	if p.config.Planner.Protected {
+		return true
+	}
+	database = p.resolveDatabase(database)
+	for name, detail := range p.config.Planner.Batches {
+		if detail != nil && detail.Protected && slices.Contains(p.Databases(Batch(name)), database) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRestoreProtection returns error wrapping ErrProtected, when the database is protected
+// and its restore from backup is not confirmed with config.Planner.ConfirmToken.
+func (p *Planner) CheckRestoreProtection(database, confirm string) error {
+	if !p.IsDatabaseProtected(database) {
+		return nil
+	}
+	return p.checkConfirm(fmt.Sprintf("restore of database '%s'", p.resolveDatabase(database)), confirm)
+}
+
+// checkConfirm compares confirmation token of the described operation with the configured one.
+func (p *Planner) checkConfirm(operation, confirm string) error {
+	if confirm == "" {
+		return fmt.Errorf("%w, %s requires confirmation token", ErrProtected, operation)
+	}
+	if subtle.ConstantTimeCompare([]byte(confirm), []byte(p.config.Planner.ConfirmToken)) != 1 {
+		return fmt.Errorf("%w, invalid confirmation token for %s", ErrProtected, operation)
+	}
+	return nil
+}
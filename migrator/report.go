@@ -0,0 +1,166 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxOutputSize limits how many bytes of stdout and stderr are kept per step.
+// When output is longer, only the tail is kept, because that is usually where the error is.
+const maxOutputSize = 64 * 1024
+
+type (
+	// ExecutionReport holds results of all executed steps. Durations are in nanoseconds when encoded to JSON.
+	// Interrupted is set, when execution stopped with ErrInterrupted, so not all steps were executed.
+	// Applied lists migration files, which were completely executed or found recorded in DB during retry.
+	ExecutionReport struct {
+		StartedAt   time.Time           `json:"started_at"`
+		FinishedAt  time.Time           `json:"finished_at"`
+		Duration    time.Duration       `json:"duration"`
+		Steps       []*StepReport       `json:"steps"`
+		Files       []*FileReport       `json:"files"`
+		Applied     []*MigrationContext `json:"applied"`
+		Error       string              `json:"error,omitempty"`
+		Interrupted bool                `json:"interrupted,omitempty"`
+	}
+
+	// FileReport holds timing of single migration file across all its steps.
+	FileReport struct {
+		Migration  *MigrationContext `json:"migration"`
+		StartedAt  time.Time         `json:"started_at"`
+		FinishedAt time.Time         `json:"finished_at"`
+		Duration   time.Duration     `json:"duration"`
+	}
+
+	// StepReport holds output, exit code and timing of single executed step.
+	// Every attempt of retried step has its own StepReport.
+	StepReport struct {
+		Args       []string          `json:"args"`
+		Input      string            `json:"input,omitempty"`
+		Migration  *MigrationContext `json:"migration,omitempty"`
+		Stdout     string            `json:"stdout"`
+		Stderr     string            `json:"stderr"`
+		ExitCode   int               `json:"exit_code"`
+		Attempt    int               `json:"attempt"`
+		StartedAt  time.Time         `json:"started_at"`
+		FinishedAt time.Time         `json:"finished_at"`
+		Duration   time.Duration     `json:"duration"`
+		Error      string            `json:"error,omitempty"`
+
+		err error
+	}
+
+	// outputBuffer keeps last maxOutputSize bytes written into it.
+	outputBuffer struct {
+		mu        sync.Mutex
+		buf       []byte
+		truncated bool
+	}
+)
+
+// Failed returns true if execution did not finish successfully.
+func (r *ExecutionReport) Failed() bool {
+	return r != nil && r.Error != ""
+}
+
+// WriteFile stores report as indented JSON into the file. Missing directories are not created.
+func (r *ExecutionReport) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(path), data, 0o644) // #nosec G306
+}
+
+// Slowest returns up to n migration files sorted by duration from the slowest one.
+func (r *ExecutionReport) Slowest(n int) []*FileReport {
+	if r == nil {
+		return nil
+	}
+	files := slices.Clone(r.Files)
+	slices.SortStableFunc(files, func(a, b *FileReport) int {
+		return cmp.Compare(b.Duration, a.Duration)
+	})
+	return files[:min(n, len(files))]
+}
+
+func (r *ExecutionReport) addStep(step *StepReport) {
+	r.Steps = append(r.Steps, step)
+	if step.Migration == nil {
+		return
+	}
+	if l := len(r.Files); l > 0 && r.Files[l-1].Migration == step.Migration {
+		r.Files[l-1].FinishedAt = step.FinishedAt
+		r.Files[l-1].Duration = step.FinishedAt.Sub(r.Files[l-1].StartedAt)
+		return
+	}
+	r.Files = append(r.Files, &FileReport{
+		Migration:  step.Migration,
+		StartedAt:  step.StartedAt,
+		FinishedAt: step.FinishedAt,
+		Duration:   step.Duration,
+	})
+}
+
+func (r *ExecutionReport) addApplied(mc *MigrationContext) {
+	if !slices.Contains(r.Applied, mc) {
+		r.Applied = append(r.Applied, mc)
+	}
+}
+
+// fileStartedAt returns start time of the migration file, if it is currently executed.
+func (r *ExecutionReport) fileStartedAt(mc *MigrationContext) time.Time {
+	if l := len(r.Files); mc != nil && l > 0 && r.Files[l-1].Migration == mc {
+		return r.Files[l-1].StartedAt
+	}
+	return time.Time{}
+}
+
+func (r *ExecutionReport) finish(err error) {
+	r.FinishedAt = time.Now()
+	r.Duration = r.FinishedAt.Sub(r.StartedAt)
+	if err != nil {
+		r.Error = err.Error()
+		r.Interrupted = errors.Is(err, ErrInterrupted)
+	}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - maxOutputSize; over > 0 {
+		b.buf = b.buf[over:]
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *outputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return "... output truncated ...\n" + string(b.buf)
+	}
+	return string(b.buf)
+}
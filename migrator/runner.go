@@ -0,0 +1,210 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"context"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+type (
+	// RunOptions specifies what should be migrated by Runner.
+	RunOptions struct {
+		// ImportDir is folder with migration folders, usually the BaseFolder from config.
+		ImportDir string
+		Target    *TargetVersion
+		Batch     Batch
+		// Clean wipes out the database according to config.Planner.WipeStrategy
+		// and runs all migrations from the beginning.
+		Clean bool
+		// Confirm must match config.Planner.ConfirmToken to clean or roll back protected batch.
+		Confirm string
+	}
+
+	// Runner runs migrations against any Neo4j instance specified in config.Connection.
+	// It does the same as supervisor, but without managing Neo4j process, so it can be used from CI.
+	Runner struct {
+		cfg     *config.Config
+		planner *Planner
+		conn    *config.Connection
+		driver  neo4j.Driver
+		process ProcessRunner
+		// interrupt is passed to every Executor, see Executor.WithInterrupt.
+		interrupt <-chan struct{}
+	}
+)
+
+// NewRunner creates Runner with driver connected according to config.Connection.
+// Config is normalized first. Processes are started with given runner, or with RunProcess if nil.
+func NewRunner(cfg *config.Config, process ProcessRunner) (*Runner, error) {
+	if err := cfg.Normalize(); err != nil {
+		return nil, err
+	}
+	driver, err := NewDriver(cfg.Connection)
+	if err != nil {
+		return nil, err
+	}
+	return NewRunnerWithDriver(cfg, driver, process)
+}
+
+// NewRunnerWithDriver creates Runner, which uses already existing driver. Config must be normalized.
+// Close will close the given driver as well.
+func NewRunnerWithDriver(cfg *config.Config, driver neo4j.Driver, process ProcessRunner) (*Runner, error) {
+	p, err := NewPlanner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{cfg: cfg, planner: p, conn: cfg.Connection, driver: driver, process: process}, nil
+}
+
+// WithInterrupt sets channel, which interrupts all executions after the running step, see Executor.WithInterrupt.
+func (r *Runner) WithInterrupt(interrupt <-chan struct{}) *Runner {
+	r.interrupt = interrupt
+	return r
+}
+
+// Close closes the driver.
+func (r *Runner) Close(ctx context.Context) error {
+	return r.driver.Close(ctx)
+}
+
+// Plan fetches current version from DB, scans local folders and returns steps, which would be executed.
+func (r *Runner) Plan(ctx context.Context, opts RunOptions) (*ExecutionSteps, error) {
+	var dbModel DatabaseModel
+	if !opts.Clean {
+		dbModels, err := r.planner.Versions(ctx, NewSessionFactory(r.driver, r.conn, neo4j.AccessModeRead))
+		if err != nil {
+			return nil, err
+		}
+		dbModel = dbModels.Merge()
+	}
+
+	scanner, err := r.planner.NewScanner(opts.ImportDir)
+	if err != nil {
+		return nil, err
+	}
+	lf, err := scanner.ScanFoldersContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := new(ExecutionSteps)
+	if opts.Clean {
+		err = r.planner.AddWipe(ctx, steps, opts.ImportDir, opts.Batch,
+			NewSessionFactory(r.driver, r.conn, neo4j.AccessModeRead))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = r.planner.PlanContext(ctx, lf, dbModel, opts.Target, opts.Batch, r.planner.CreateBuilder(steps, true))
+	if err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// Run plans and executes migrations. Report is returned always when execution started, even if it failed.
+// Missing databases are created first, if enabled in config.Connection.
+// Clean run or rollback of protected batch must be confirmed, see Planner.CheckProtection.
+func (r *Runner) Run(ctx context.Context, opts RunOptions) (_ *ExecutionReport, err error) {
+	ctx, span := tracer().Start(ctx, "Runner.Run", trace.WithAttributes(
+		attrBatch.String(string(opts.Batch)),
+		attrTarget.String(opts.Target.String()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err = r.createDatabases(ctx, opts.Batch); err != nil {
+		return nil, err
+	}
+	steps, err := r.Plan(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.planner.CheckProtection(opts.Batch, opts.Clean, *steps, opts.Confirm); err != nil {
+		return nil, err
+	}
+	return r.NewExecutor(opts.Batch).Execute(ctx, *steps)
+}
+
+// NewExecutor creates Executor, which connects to the same DB as Runner and checks bookkeeping before retries.
+func (r *Runner) NewExecutor(batch Batch) *Executor {
+	env := append(ConnectionEnv(r.conn), EnvMigrationBatch+"="+string(batch))
+	return r.planner.NewExecutor(r.process, env...).
+		WithAppliedChecker(r.planner.NewAppliedChecker(r.driver, r.conn)).
+		WithInterrupt(r.interrupt)
+}
+
+func (r *Runner) createDatabases(ctx context.Context, batch Batch) error {
+	if !r.conn.CreateDatabase {
+		return nil
+	}
+	_, err := r.planner.CreateDatabases(ctx, NewSessionFactory(r.driver, r.conn, neo4j.AccessModeWrite), batch)
+	return err
+}
+
+// Tenants returns names of tenant databases according to config.Tenants.
+func (r *Runner) Tenants(ctx context.Context) ([]string, error) {
+	return TenantDatabases(ctx, r.cfg.Tenants, NewSessionFactory(r.driver, r.conn, neo4j.AccessModeRead))
+}
+
+// RunTenants runs the same migrations in every tenant database, see RunTenants function.
+// Tenant, where the plan is empty, is reported as already current.
+func (r *Runner) RunTenants(ctx context.Context, opts RunOptions) (*TenantsReport, error) {
+	databases, err := r.Tenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return RunTenants(ctx, databases, r.cfg.Tenants.Parallelism, r.tenantFunc(opts))
+}
+
+func (r *Runner) tenantFunc(opts RunOptions) TenantFunc {
+	return func(ctx context.Context, database string) (_ *ExecutionReport, err error) {
+		ctx, span := tracer().Start(ctx, "Runner.Tenant", trace.WithAttributes(attrDatabase.String(database)))
+		defer func() { endSpan(span, err) }()
+
+		tenant, err := r.forTenant(database)
+		if err != nil {
+			return nil, err
+		}
+		if err = tenant.createDatabases(ctx, opts.Batch); err != nil {
+			return nil, err
+		}
+		steps, err := tenant.Plan(ctx, opts)
+		if err != nil || steps.IsEmpty() {
+			return nil, err
+		}
+		if err = tenant.planner.CheckProtection(opts.Batch, opts.Clean, *steps, opts.Confirm); err != nil {
+			return nil, err
+		}
+		return tenant.NewExecutor(opts.Batch).Execute(ctx, *steps)
+	}
+}
+
+// forTenant returns Runner sharing the driver, which uses given database instead of the one from connection config.
+func (r *Runner) forTenant(database string) (*Runner, error) {
+	conn := *r.conn
+	conn.Database = database
+	cfg := *r.cfg
+	cfg.Connection = &conn
+	tenant, err := NewRunnerWithDriver(&cfg, r.driver, r.process)
+	if err != nil {
+		return nil, err
+	}
+	return tenant.WithInterrupt(r.interrupt), nil
+}
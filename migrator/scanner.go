@@ -15,6 +15,8 @@
 package migrator
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/indykite/neo4j-graph-tool-core/config"
 )
@@ -93,6 +96,9 @@ const (
 	Command
 )
 
+// snapshotsFolder is the name of folder with snapshots, which is also used as FolderName of snapshot files.
+const snapshotsFolder = "snapshots"
+
 var (
 	upDownFilePattern   = regexp.MustCompile(`(?i)^(?P<commit>\d+)_(?P<direction>up|down)_(?P<name>\w+)\.(?P<type>cypher|run)$`) //nolint:lll
 	changeFilePattern   = regexp.MustCompile(`(?i)^(?P<commit>\d+)_(?P<name>\w+)\.(?P<type>cypher|run)$`)
@@ -162,6 +168,35 @@ func (dbm DatabaseModel) MarshalJSON() ([]byte, error) {
 	return []byte(dbm.toJSON(math.MaxInt)), nil
 }
 
+// UnmarshalJSON parses DatabaseModel encoded by MarshalJSON. Versions of every folder are sorted.
+func (dbm *DatabaseModel) UnmarshalJSON(data []byte) error {
+	raw := map[string]map[string][]int64{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	model := make(DatabaseModel, len(raw))
+	for folderName, versions := range raw {
+		folderVersions := make([]DatabaseGraphVersion, 0, len(versions))
+		for ver, timestamps := range versions {
+			version, err := semver.NewVersion(ver)
+			if err != nil {
+				return fmt.Errorf("invalid version '%s' of folder '%s': %w", ver, folderName, err)
+			}
+			gv := DatabaseGraphVersion{Version: version, FileTimestamps: make(map[int64]bool, len(timestamps))}
+			for _, ts := range timestamps {
+				gv.FileTimestamps[ts] = true
+			}
+			folderVersions = append(folderVersions, gv)
+		}
+		sort.Slice(folderVersions, func(i, j int) bool {
+			return folderVersions[i].Version.LessThan(folderVersions[j].Version)
+		})
+		model[folderName] = folderVersions
+	}
+	*dbm = model
+	return nil
+}
+
 // ContainsHigherVersion check if there is folder with higher version than specified.
 func (dbm DatabaseModel) ContainsHigherVersion(folder string, version *semver.Version) bool {
 	folderVersion, hasFolder := dbm[folder]
@@ -281,7 +316,15 @@ func (s *Scanner) resolve(dir string) string {
 // ScanFolders start scanning and returns all up and down files divided per version and revision.
 // Result is not sorted by default, use SortByVersion() to sort it by semver version.
 func (s *Scanner) ScanFolders() (LocalFolders, error) {
-	localFolders, err := s.scanSchemaAndExtraFolders()
+	return s.ScanFoldersContext(context.Background())
+}
+
+// ScanFoldersContext is like ScanFolders, but the context is used as parent of the tracing span.
+func (s *Scanner) ScanFoldersContext(ctx context.Context) (localFolders LocalFolders, err error) {
+	_, span := tracer().Start(ctx, "Scanner.ScanFolders", trace.WithAttributes(attrPath.String(s.baseDir)))
+	defer func() { endSpan(span, err) }()
+
+	localFolders, err = s.scanSchemaAndExtraFolders()
 	if err != nil {
 		return nil, err
 	}
@@ -290,6 +333,7 @@ func (s *Scanner) ScanFolders() (LocalFolders, error) {
 		return nil, err
 	}
 
+	span.SetAttributes(attrVersions.Int(len(localFolders)))
 	return localFolders, err
 }
 
@@ -349,7 +393,7 @@ func (s *Scanner) scanSchemaAndExtraFolders() (LocalFolders, error) {
 }
 
 func (s *Scanner) addSnapshotsTo(localFolders LocalFolders) error {
-	dirPath := s.resolve(filepath.Clean("snapshots"))
+	dirPath := s.resolve(filepath.Clean(snapshotsFolder))
 	f, err := os.Open(filepath.Clean(dirPath))
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -404,7 +448,7 @@ func (s *Scanner) addSnapshotsTo(localFolders LocalFolders) error {
 				continue
 			}
 			localFolder.Snapshots[Batch(batchName)] = &MigrationFile{
-				FolderName: "snapshots",
+				FolderName: snapshotsFolder,
 				Path:       path.Join(dirPath, fileName),
 				FileType:   fileType,
 				IsSnapshot: true,
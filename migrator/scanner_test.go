@@ -234,6 +234,27 @@ var _ = Describe("GraphVersion", func() {
 		  }`))
 		Expect(jsonResult).NotTo(ContainSubstring("\n"))
 	})
+
+	It("DatabaseModel UnmarshalJSON", func() {
+		jsonResult, err := json.Marshal(dbModel)
+		Expect(err).To(Succeed())
+
+		var parsed migrator.DatabaseModel
+		Expect(json.Unmarshal(jsonResult, &parsed)).To(Succeed())
+		Expect(parsed).To(HaveLen(2))
+		version := func(v string) OmegaMatcher { return WithTransform((*semver.Version).String, Equal(v)) }
+		Expect(parsed["schema"]).To(HaveExactElements(
+			MatchAllFields(Fields{"Version": version("1.0.0"), "FileTimestamps": HaveLen(5)}),
+			MatchAllFields(Fields{"Version": version("1.1.0"), "FileTimestamps": HaveKey(int64(1677070000))}),
+			MatchAllFields(Fields{"Version": version("2.0.0"), "FileTimestamps": HaveKey(int64(1677090002))}),
+		))
+		reencoded, err := json.Marshal(parsed)
+		Expect(err).To(Succeed())
+		Expect(reencoded).To(MatchJSON(jsonResult))
+
+		err = json.Unmarshal([]byte(`{"schema": {"abc": [1677090000]}}`), &parsed)
+		Expect(err).To(MatchError(ContainSubstring("invalid version 'abc' of folder 'schema'")))
+	})
 })
 
 var _ = Describe("TargetVersion", func() {
@@ -0,0 +1,205 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+// SystemDatabase is the database for administration commands, where tenants query is executed as well.
+const SystemDatabase = "system"
+
+// TenantStatus describes result of migrating single tenant database.
+type TenantStatus string
+
+const (
+	TenantSucceeded TenantStatus = "succeeded"
+	TenantFailed    TenantStatus = "failed"
+	TenantCurrent   TenantStatus = "current"
+)
+
+type (
+	// TenantFunc migrates single tenant database. Nil report without error means the database was already current.
+	TenantFunc func(ctx context.Context, database string) (*ExecutionReport, error)
+
+	// TenantsReport holds consolidated results of migrating all tenant databases.
+	// Tenants are in the same order as databases passed to RunTenants.
+	TenantsReport struct {
+		StartedAt  time.Time       `json:"started_at"`
+		FinishedAt time.Time       `json:"finished_at"`
+		Duration   time.Duration   `json:"duration"`
+		Succeeded  []string        `json:"succeeded"`
+		Failed     []string        `json:"failed"`
+		Current    []string        `json:"current"`
+		Tenants    []*TenantReport `json:"tenants"`
+	}
+
+	// TenantReport holds result of single tenant database. Report is nil when nothing was executed.
+	TenantReport struct {
+		Database string           `json:"database"`
+		Status   TenantStatus     `json:"status"`
+		Report   *ExecutionReport `json:"report,omitempty"`
+		Error    string           `json:"error,omitempty"`
+	}
+)
+
+// TenantDatabases returns unique names of tenant databases from static list, file and query in this order.
+// File contains one database per line, empty lines and lines starting with '#' are ignored.
+// Query is executed against system database and names are taken from the first column.
+func TenantDatabases(ctx context.Context, tenants *config.Tenants, newSession SessionFactory) ([]string, error) {
+	if !tenants.Configured() {
+		return nil, errors.New("no tenant databases are configured")
+	}
+	databases := append([]string{}, tenants.Databases...)
+
+	if tenants.File != "" {
+		fromFile, err := readTenantsFile(tenants.File)
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, fromFile...)
+	}
+
+	if tenants.Query != "" {
+		session := newSession(ctx, SystemDatabase)
+		fromQuery, err := queryStrings(ctx, session, tenants.Query)
+		_ = session.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch tenant databases: %w", err)
+		}
+		databases = append(databases, fromQuery...)
+	}
+
+	seen := make(map[string]bool, len(databases))
+	unique := databases[:0]
+	for _, db := range databases {
+		if !seen[db] {
+			seen[db] = true
+			unique = append(unique, db)
+		}
+	}
+	return unique, nil
+}
+
+func readTenantsFile(path string) ([]string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tenants file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var databases []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		databases = append(databases, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read tenants file: %w", err)
+	}
+	return databases, nil
+}
+
+// RunTenants migrates all databases with at most parallelism of them at once.
+// Failure of one tenant does not stop others, error is returned when any of them failed.
+func RunTenants(
+	ctx context.Context,
+	databases []string,
+	parallelism int,
+	migrate TenantFunc,
+) (*TenantsReport, error) {
+	report := &TenantsReport{
+		StartedAt: time.Now(),
+		Tenants:   make([]*TenantReport, len(databases)),
+	}
+
+	g := new(errgroup.Group)
+	g.SetLimit(max(parallelism, 1))
+	for i, db := range databases {
+		g.Go(func() error {
+			// Every goroutine writes only its own item, so no locking is needed.
+			report.Tenants[i] = runTenant(ctx, db, migrate)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	report.finish()
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf("migration failed for %d of %d tenants: %s",
+			len(report.Failed), len(databases), strings.Join(report.Failed, ", "))
+	}
+	return report, nil
+}
+
+func runTenant(ctx context.Context, database string, migrate TenantFunc) *TenantReport {
+	tr := &TenantReport{Database: database}
+	if err := ctx.Err(); err != nil {
+		tr.Status, tr.Error = TenantFailed, err.Error()
+		return tr
+	}
+
+	report, err := migrate(ctx, database)
+	tr.Report = report
+	switch {
+	case err != nil:
+		tr.Status, tr.Error = TenantFailed, err.Error()
+	case report == nil:
+		tr.Status = TenantCurrent
+	default:
+		tr.Status = TenantSucceeded
+	}
+	return tr
+}
+
+func (r *TenantsReport) finish() {
+	r.FinishedAt = time.Now()
+	r.Duration = r.FinishedAt.Sub(r.StartedAt)
+	r.Succeeded, r.Failed, r.Current = []string{}, []string{}, []string{}
+	for _, t := range r.Tenants {
+		switch t.Status {
+		case TenantSucceeded:
+			r.Succeeded = append(r.Succeeded, t.Database)
+		case TenantFailed:
+			r.Failed = append(r.Failed, t.Database)
+		case TenantCurrent:
+			r.Current = append(r.Current, t.Database)
+		}
+	}
+}
+
+// WriteFile stores report as indented JSON into the file. Missing directories are not created.
+func (r *TenantsReport) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(path), data, 0o644) // #nosec G306
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j/db"
+	"go.uber.org/mock/gomock"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+	"github.com/indykite/neo4j-graph-tool-core/test"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+var _ = Describe("Tenants", func() {
+	Describe("TenantDatabases", func() {
+		var (
+			mockCtrl    *gomock.Controller
+			mockTx      *test.MockManagedTransaction
+			mockResult  *test.MockResult
+			usedDBs     []string
+			sessionFunc migrator.SessionFactory
+		)
+
+		BeforeEach(func() {
+			mockCtrl = gomock.NewController(GinkgoT())
+			mockTx = test.NewMockManagedTransaction(mockCtrl)
+			mockResult = test.NewMockResult(mockCtrl)
+			usedDBs = nil
+			sessionFunc = func(_ context.Context, database string) neo4j.Session {
+				usedDBs = append(usedDBs, database)
+				return &MockSession{tx: mockTx}
+			}
+		})
+
+		It("Fails when nothing is configured", func() {
+			_, err := migrator.TenantDatabases(context.Background(), &config.Tenants{Parallelism: 1}, sessionFunc)
+			Expect(err).To(MatchError("no tenant databases are configured"))
+		})
+
+		It("Collects unique databases from all sources", func() {
+			file := filepath.Join(GinkgoT().TempDir(), "tenants.txt")
+			Expect(os.WriteFile(file, []byte("# tenants\ntenant-b\n\n  tenant-c  \ntenant-a\n"), 0o600)).To(Succeed())
+
+			query := "SHOW DATABASES YIELD name WHERE name STARTS WITH 'tenant-' RETURN name"
+			mockTx.EXPECT().Run(gomock.Any(), query, nil).Return(mockResult, nil)
+			mockResult.EXPECT().Collect(gomock.Any()).Return([]*db.Record{
+				{Keys: []string{"name"}, Values: []any{"tenant-d"}},
+				{Keys: []string{"name"}, Values: []any{"tenant-c"}},
+			}, nil)
+
+			dbs, err := migrator.TenantDatabases(context.Background(), &config.Tenants{
+				Databases: []string{"tenant-a"},
+				File:      file,
+				Query:     query,
+			}, sessionFunc)
+			Expect(err).To(Succeed())
+			Expect(dbs).To(Equal([]string{"tenant-a", "tenant-b", "tenant-c", "tenant-d"}))
+			Expect(usedDBs).To(Equal([]string{migrator.SystemDatabase}))
+		})
+
+		It("Fails on missing file", func() {
+			_, err := migrator.TenantDatabases(context.Background(), &config.Tenants{
+				File: "testdata/missing-tenants.txt",
+			}, sessionFunc)
+			Expect(err).To(MatchError(ContainSubstring("cannot read tenants file")))
+		})
+
+		It("Fails on invalid query result", func() {
+			mockTx.EXPECT().Run(gomock.Any(), "RETURN 1", nil).Return(mockResult, nil)
+			mockResult.EXPECT().Collect(gomock.Any()).Return([]*db.Record{
+				{Keys: []string{"1"}, Values: []any{int64(1)}},
+			}, nil)
+
+			_, err := migrator.TenantDatabases(context.Background(), &config.Tenants{Query: "RETURN 1"}, sessionFunc)
+			Expect(err).To(MatchError(
+				"cannot fetch tenant databases: invalid value '1' returned by 'RETURN 1'"))
+		})
+	})
+
+	Describe("RunTenants", func() {
+		It("Reports every tenant status", func() {
+			migrate := func(_ context.Context, database string) (*migrator.ExecutionReport, error) {
+				switch database {
+				case "tenant-current":
+					return nil, nil
+				case "tenant-plan-failed":
+					return nil, errors.New("cannot plan")
+				case "tenant-failed":
+					return &migrator.ExecutionReport{Error: "exit status 1"}, errors.New("exit status 1")
+				}
+				return &migrator.ExecutionReport{}, nil
+			}
+
+			report, err := migrator.RunTenants(context.Background(), []string{
+				"tenant-ok", "tenant-current", "tenant-plan-failed", "tenant-failed",
+			}, 2, migrate)
+			Expect(err).To(MatchError("migration failed for 2 of 4 tenants: tenant-plan-failed, tenant-failed"))
+			Expect(report.Succeeded).To(Equal([]string{"tenant-ok"}))
+			Expect(report.Current).To(Equal([]string{"tenant-current"}))
+			Expect(report.Failed).To(Equal([]string{"tenant-plan-failed", "tenant-failed"}))
+			Expect(report.Tenants).To(HaveExactElements(
+				PointTo(MatchAllFields(Fields{
+					"Database": Equal("tenant-ok"),
+					"Status":   Equal(migrator.TenantSucceeded),
+					"Report":   Not(BeNil()),
+					"Error":    BeEmpty(),
+				})),
+				PointTo(MatchAllFields(Fields{
+					"Database": Equal("tenant-current"),
+					"Status":   Equal(migrator.TenantCurrent),
+					"Report":   BeNil(),
+					"Error":    BeEmpty(),
+				})),
+				PointTo(MatchAllFields(Fields{
+					"Database": Equal("tenant-plan-failed"),
+					"Status":   Equal(migrator.TenantFailed),
+					"Report":   BeNil(),
+					"Error":    Equal("cannot plan"),
+				})),
+				PointTo(MatchAllFields(Fields{
+					"Database": Equal("tenant-failed"),
+					"Status":   Equal(migrator.TenantFailed),
+					"Report":   PointTo(MatchFields(IgnoreExtras, Fields{"Error": Equal("exit status 1")})),
+					"Error":    Equal("exit status 1"),
+				})),
+			))
+			Expect(report.FinishedAt).To(BeTemporally(">=", report.StartedAt))
+		})
+
+		It("Limits parallelism", func() {
+			var running, maxRunning atomic.Int32
+			migrate := func(context.Context, string) (*migrator.ExecutionReport, error) {
+				n := running.Add(1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				return nil, nil
+			}
+
+			report, err := migrator.RunTenants(context.Background(), []string{
+				"tenant-a", "tenant-b", "tenant-c", "tenant-d", "tenant-e",
+			}, 2, migrate)
+			Expect(err).To(Succeed())
+			Expect(report.Current).To(HaveLen(5))
+			Expect(report.Failed).To(BeEmpty())
+			Expect(maxRunning.Load()).To(BeNumerically("<=", 2))
+		})
+
+		It("Does not start tenants after context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			called := false
+			report, err := migrator.RunTenants(ctx, []string{"tenant-a"}, 1,
+				func(context.Context, string) (*migrator.ExecutionReport, error) {
+					called = true
+					return nil, nil
+				})
+			Expect(err).To(HaveOccurred())
+			Expect(called).To(BeFalse())
+			Expect(report.Failed).To(Equal([]string{"tenant-a"}))
+			Expect(report.Tenants[0].Error).To(Equal(context.Canceled.Error()))
+		})
+	})
+})
@@ -0,0 +1,126 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+// tracerName is the instrumentation scope of all migrator spans.
+const tracerName = "github.com/indykite/neo4j-graph-tool-core/migrator"
+
+// Span attributes set by migrator.
+const (
+	attrFolder    = attribute.Key("graph_tool.folder")
+	attrVersion   = attribute.Key("graph_tool.version")
+	attrRevision  = attribute.Key("graph_tool.revision")
+	attrFileType  = attribute.Key("graph_tool.file_type")
+	attrPath      = attribute.Key("graph_tool.path")
+	attrDatabase  = attribute.Key("graph_tool.database")
+	attrBatch     = attribute.Key("graph_tool.batch")
+	attrTarget    = attribute.Key("graph_tool.target_version")
+	attrAttempt   = attribute.Key("graph_tool.attempt")
+	attrSteps     = attribute.Key("graph_tool.steps")
+	attrVersions  = attribute.Key("graph_tool.versions")
+	attrFolders   = attribute.Key("graph_tool.folders")
+	attrExitCode  = attribute.Key("graph_tool.exit_code")
+	attrDirection = attribute.Key("graph_tool.direction")
+)
+
+// Tracer is obtained from global provider every time, so provider set after package init is used.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// StartTracing sets global OpenTelemetry tracer provider with OTLP HTTP exporter, when tracing is enabled.
+// Returned function flushes and stops the exporter and must be called before exit. It is no-op when disabled.
+func StartTracing(ctx context.Context, cfg *config.Tracing) (func(context.Context) error, error) {
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{}
+	if strings.Contains(cfg.Endpoint, "://") {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	} else {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// stepAttributes describes the step and its migration file, if there is any.
+func stepAttributes(step ExecutionStep) []attribute.KeyValue {
+	fileType := "command"
+	if step.IsCypher() {
+		fileType = "cypher"
+	}
+	attrs := []attribute.KeyValue{attrFileType.String(fileType)}
+	mc := step.Migration()
+	if mc == nil {
+		return attrs
+	}
+	attrs = append(attrs,
+		attrFolder.String(mc.FolderName),
+		attrRevision.Int64(mc.Revision),
+		attrPath.String(mc.Path),
+		attrDirection.String(mc.Direction()),
+	)
+	if mc.Version != nil {
+		attrs = append(attrs, attrVersion.String(mc.Version.String()))
+	}
+	if mc.Database != "" {
+		attrs = append(attrs, attrDatabase.String(mc.Database))
+	}
+	return attrs
+}
+
+// endSpan records error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
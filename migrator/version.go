@@ -22,6 +22,9 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
 )
 
 type Neo4jSession struct {
@@ -31,39 +34,70 @@ type Neo4jSession struct {
 //nolint:lll
 const versionCypher = `MATCH (sm:%s) WHERE sm.deleted_at IS NULL RETURN sm.version AS version, collect(sm.file) AS files`
 
-// Version retrieves version of current state of DB.
-func (p *Planner) Version(ctx context.Context, session neo4j.Session) (DatabaseModel, error) {
-	var err error
+const appliedCypher = `MATCH (sm:%s {version: $version, file: $file}) RETURN sm.deleted_at IS NULL AS active`
 
-	dbModel := make(DatabaseModel)
-	var dgv []DatabaseGraphVersion
-	dgv, err = queryVersion(ctx, session, fmt.Sprintf(
-		versionCypher,
-		strings.Join(p.config.Planner.SchemaFolder.NodeLabels, ":"),
-	))
-	if len(dgv) > 0 {
-		dbModel[p.config.Planner.SchemaFolder.FolderName] = dgv
-	}
-	if err != nil {
-		return nil, err
+// SessionFactory opens new session to given database. Empty name means database from the connection config.
+type SessionFactory func(ctx context.Context, database string) neo4j.Session
+
+// Versions retrieves version of current state of all databases, where folders are stored.
+// Every database is queried only for folders, which belong to it.
+func (p *Planner) Versions(ctx context.Context, newSession SessionFactory) (_ DatabaseModels, err error) {
+	ctx, span := tracer().Start(ctx, "Planner.Versions")
+	defer func() { endSpan(span, err) }()
+
+	dbModels := make(DatabaseModels)
+	for database, folders := range p.foldersByDatabase() {
+		session := newSession(ctx, database)
+		var dbModel DatabaseModel
+		dbModel, err = p.queryFolders(ctx, session, database, folders)
+		_ = session.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch version from database '%s': %w", database, err)
+		}
+		dbModels[database] = dbModel
 	}
+	return dbModels, nil
+}
+
+// queryFolders queries version of given folders. Database is used only as span attribute.
+func (p *Planner) queryFolders(
+	ctx context.Context,
+	session neo4j.Session,
+	database string,
+	folders []string,
+) (_ DatabaseModel, err error) {
+	ctx, span := tracer().Start(ctx, "Planner.Version", trace.WithAttributes(
+		attrDatabase.String(database),
+		attrFolders.StringSlice(folders),
+	))
+	defer func() { endSpan(span, err) }()
 
-	for folderName, folderDetail := range p.config.Planner.Folders {
-		dgv, err = queryVersion(ctx, session, fmt.Sprintf(
+	dbModel := make(DatabaseModel)
+	for _, folderName := range folders {
+		dgv, err := queryVersion(ctx, session, fmt.Sprintf(
 			versionCypher,
-			strings.Join(folderDetail.NodeLabels, ":"),
+			strings.Join(p.nodeLabels(folderName), ":"),
 		))
-		if len(dgv) > 0 {
-			dbModel[folderName] = dgv
-		}
 		if err != nil {
 			return nil, err
 		}
+		if len(dgv) > 0 {
+			dbModel[folderName] = dgv
+		}
 	}
-
 	return dbModel, nil
 }
 
+// Version retrieves version of current state of DB. All folders are read from the given session,
+// use Versions when folders are mapped to multiple databases.
+func (p *Planner) Version(ctx context.Context, session neo4j.Session) (DatabaseModel, error) {
+	folders := []string{p.config.Planner.SchemaFolder.FolderName}
+	for folderName := range p.config.Planner.Folders {
+		folders = append(folders, folderName)
+	}
+	return p.queryFolders(ctx, session, "", folders)
+}
+
 func queryVersion(
 	ctx context.Context,
 	session neo4j.Session,
@@ -131,3 +165,57 @@ func queryVersion(
 
 	return gs, err
 }
+
+// NewAppliedChecker returns AppliedChecker, which opens new session to the database of the migration file
+// for every check.
+func (p *Planner) NewAppliedChecker(driver neo4j.Driver, conn *config.Connection) AppliedChecker {
+	newSession := NewSessionFactory(driver, conn, neo4j.AccessModeWrite)
+	return func(ctx context.Context, mc *MigrationContext) (bool, error) {
+		session := newSession(ctx, mc.Database)
+		defer func() { _ = session.Close(ctx) }()
+		return p.IsApplied(ctx, session, mc)
+	}
+}
+
+// IsApplied checks bookkeeping node, if the migration file is already recorded in DB.
+// Upgrade is applied when the node exists and is not deleted, downgrade when the node is marked as deleted.
+// Snapshots are not recorded, so they are never reported as applied.
+func (p *Planner) IsApplied(ctx context.Context, session neo4j.Session, mc *MigrationContext) (bool, error) {
+	if mc == nil || mc.IsSnapshot {
+		return false, nil
+	}
+	nodeLabels := p.nodeLabels(mc.FolderName)
+	if len(nodeLabels) == 0 {
+		return false, fmt.Errorf("cannot determine DB labels of folder '%s'", mc.FolderName)
+	}
+
+	// Write transaction is routed to the leader in cluster, so it sees bookkeeping committed just now.
+	res, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, fmt.Sprintf(appliedCypher, strings.Join(nodeLabels, ":")), map[string]any{
+			"version": mc.Version.String(),
+			"file":    mc.Revision,
+		})
+		if err != nil {
+			return nil, err
+		}
+		records, err := result.Collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return false, nil
+		}
+		for _, record := range records {
+			active, _ := record.Get("active")
+			if active == true {
+				return !mc.IsDowngrade, nil
+			}
+		}
+		return mc.IsDowngrade, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	applied, _ := res.(bool)
+	return applied, nil
+}
@@ -246,4 +246,45 @@ var _ = Describe("Version", func() {
 		  }
 		  `))
 	})
+
+	DescribeTable("IsApplied",
+		func(isDowngrade bool, active []any, expected bool) {
+			mockTransaction.EXPECT().Run(
+				gomock.Any(),
+				"MATCH (sm:DataVersion {version: $version, file: $file}) RETURN sm.deleted_at IS NULL AS active",
+				map[string]any{"version": "1.0.0", "file": int64(1250)},
+			).DoAndReturn(func(_, _, _ any) (neo4j.Result, error) {
+				records := make([]*db.Record, 0, len(active))
+				for _, a := range active {
+					records = append(records, &db.Record{Keys: []string{"active"}, Values: []any{a}})
+				}
+				mockResult.EXPECT().Collect(gomock.Any()).Return(records, nil)
+				return mockResult, nil
+			})
+
+			applied, err := p.IsApplied(context.Background(), session, &migrator.MigrationContext{
+				FolderName: "data", Version: v100, Revision: 1250, IsDowngrade: isDowngrade,
+			})
+			Expect(err).To(Succeed())
+			Expect(applied).To(Equal(expected))
+		},
+		Entry("Upgrade without node", false, []any{}, false),
+		Entry("Upgrade with active node", false, []any{true}, true),
+		Entry("Upgrade with deleted node", false, []any{false}, false),
+		Entry("Downgrade without node", true, []any{}, false),
+		Entry("Downgrade with active node", true, []any{true}, false),
+		Entry("Downgrade with deleted node", true, []any{false}, true),
+	)
+
+	It("IsApplied ignores snapshots and fails on unknown folder", func() {
+		applied, err := p.IsApplied(context.Background(), session, &migrator.MigrationContext{
+			FolderName: "data", Version: v100, IsSnapshot: true,
+		})
+		Expect(err).To(Succeed())
+		Expect(applied).To(BeFalse())
+
+		_, err = p.IsApplied(context.Background(), session,
+			&migrator.MigrationContext{FolderName: "abc", Version: v100})
+		Expect(err).To(MatchError("cannot determine DB labels of folder 'abc'"))
+	})
 })
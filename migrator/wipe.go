@@ -0,0 +1,151 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+const (
+	showConstraintsCypher = `SHOW CONSTRAINTS YIELD name RETURN name`
+	// Indexes backing constraints are dropped together with constraints.
+	// Token lookup indexes are kept, as they exist also in every new database.
+	showIndexesCypher = `SHOW INDEXES YIELD name, type, owningConstraint ` +
+		`WHERE owningConstraint IS NULL AND type <> 'LOOKUP' RETURN name`
+)
+
+// AddWipe adds steps, which wipe out all databases of the batch according to config.Planner.WipeStrategy.
+// Sessions are used to list constraints and indexes by 'delete' strategy and to check edition by 'replace'.
+// Drop Cypher file from the import dir is used by 'file' strategy, see AddDrop.
+func (p *Planner) AddWipe(
+	ctx context.Context,
+	steps *ExecutionSteps,
+	importDir string,
+	batch Batch,
+	newSession SessionFactory,
+) error {
+	switch p.config.Planner.WipeStrategy {
+	case config.WipeDelete:
+		return p.addPerDatabase(steps, batch, func(db, use string) error {
+			steps.AddCypher(use)
+			return p.addDeleteWipe(ctx, steps, db, newSession)
+		})
+	case config.WipeReplace:
+		if err := checkEnterprise(ctx, newSession); err != nil {
+			return err
+		}
+		// Database is replaced from system database, so it is not selected.
+		return p.addPerDatabase(steps, batch, func(db, _ string) error {
+			if db == "" {
+				return errors.New("replace wipe strategy requires database name, set connection.database")
+			}
+			steps.AddCypher(":use ", SystemDatabase, "\n",
+				"CREATE OR REPLACE DATABASE ", quoteName(db), " WAIT;\n")
+			return nil
+		})
+	default:
+		return p.AddDrop(steps, importDir, batch)
+	}
+}
+
+// addDeleteWipe deletes all nodes in batches and drops all constraints and indexes existing in the database now.
+func (p *Planner) addDeleteWipe(
+	ctx context.Context,
+	steps *ExecutionSteps,
+	db string,
+	newSession SessionFactory,
+) error {
+	session := newSession(ctx, db)
+	defer func() { _ = session.Close(ctx) }()
+
+	constraints, err := queryStrings(ctx, session, showConstraintsCypher)
+	if err != nil {
+		return fmt.Errorf("cannot list constraints: %w", err)
+	}
+	indexes, err := queryStrings(ctx, session, showIndexesCypher)
+	if err != nil {
+		return fmt.Errorf("cannot list indexes: %w", err)
+	}
+
+	steps.AddCypher("MATCH (n) CALL { WITH n DETACH DELETE n } IN TRANSACTIONS OF ",
+		strconv.Itoa(p.wipeBatchSize()), " ROWS;\n")
+	slices.Sort(constraints)
+	for _, name := range constraints {
+		steps.AddCypher("DROP CONSTRAINT ", quoteName(name), " IF EXISTS;\n")
+	}
+	slices.Sort(indexes)
+	for _, name := range indexes {
+		steps.AddCypher("DROP INDEX ", quoteName(name), " IF EXISTS;\n")
+	}
+	return nil
+}
+
+// addPerDatabase adds header for every database of the batch followed by steps added by add function.
+// Add function receives :use command, which selects the database, and decides if it is needed.
+// With single database of the connection, steps run in the database of the session and the command is empty.
+func (p *Planner) addPerDatabase(steps *ExecutionSteps, batch Batch, add func(db, use string) error) error {
+	databases := p.Databases(batch)
+	for _, db := range databases {
+		if len(databases) == 1 {
+			steps.AddCypher("// wipe out the entire database\n")
+		} else {
+			steps.AddCypher("// wipe out the entire database ", db, "\n")
+		}
+		use := ""
+		// Empty name is default database of the session, which is used before any :use command.
+		if db != "" && (len(databases) > 1 || db != p.connectionDatabase()) {
+			use = ":use " + db + "\n"
+		}
+		if err := add(db, use); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Planner) wipeBatchSize() int {
+	if p.config.Planner.WipeBatchSize > 0 {
+		return p.config.Planner.WipeBatchSize
+	}
+	return config.DefaultWipeBatchSize
+}
+
+// checkEnterprise returns error, when Neo4j is not Enterprise edition.
+func checkEnterprise(ctx context.Context, newSession SessionFactory) error {
+	session := newSession(ctx, SystemDatabase)
+	defer func() { _ = session.Close(ctx) }()
+
+	editions, err := queryStrings(ctx, session, editionCypher)
+	if err != nil {
+		return fmt.Errorf("cannot detect Neo4j edition: %w", err)
+	}
+	if !slices.Contains(editions, "enterprise") {
+		return fmt.Errorf("replace wipe strategy requires Enterprise edition, but Neo4j is %s edition",
+			strings.Join(editions, ","))
+	}
+	return nil
+}
+
+// quoteName escapes name of database, constraint or index for Cypher.
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,374 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+)
+
+const apiV1Prefix = "/api/v1"
+
+// Error codes returned in apiError.Code.
+const (
+	errCodeInvalidRequest   = "invalid_request"
+	errCodeUnauthorized     = "unauthorized"
+	errCodeForbidden        = "forbidden"
+	errCodeNotFound         = "not_found"
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeConflict         = "conflict"
+	errCodeInternal         = "internal"
+	errCodeUnavailable      = "unavailable"
+)
+
+type (
+	// apiError is the error object of all /api/v1 responses.
+	apiError struct {
+		Status  int    `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	// migrationRequest is JSON body of migration endpoints. All fields are optional,
+	// supervisor defaults are used for target version and batch.
+	// ConfirmToken is required to clean or roll back protected batch.
+	migrationRequest struct {
+		TargetVersion string        `json:"target_version"`
+		Batch         string        `json:"batch"`
+		DryRun        bool          `json:"dry_run"`
+		Retry         *retryRequest `json:"retry"`
+		ConfirmToken  string        `json:"confirm_token"`
+	}
+
+	// backupRequest is optional JSON body of backup creation. Name is generated when empty.
+	backupRequest struct {
+		Name string `json:"name"`
+	}
+
+	// restoreRequest is optional JSON body of backup and checkpoint restore.
+	// ConfirmToken is required to restore protected database or checkpoint of protected batch.
+	restoreRequest struct {
+		ConfirmToken string `json:"confirm_token"`
+	}
+
+	// retryRequest overrides only set values of planner.retry config. Durations are in Go format, like '500ms'.
+	retryRequest struct {
+		MaxAttempts    int      `json:"max_attempts"`
+		InitialBackoff string   `json:"initial_backoff"`
+		MaxBackoff     string   `json:"max_backoff"`
+		RetryableCodes []string `json:"retryable_codes"`
+	}
+)
+
+func (s *httpServer) registerAPIv1(g *gin.Engine) {
+	api := g.Group(apiV1Prefix)
+	read := api.Group("", s.authorize(config.RoleRead))
+	read.GET("/status", s.wrapperStatusHandler)
+	read.GET("/health", s.apiHealthHandler)
+	read.GET("/version", s.apiVersionHandler)
+	read.GET("/report", s.apiReportHandler)
+	read.GET("/jobs", s.apiJobsHandler)
+	read.GET("/jobs/:id", s.apiJobHandler)
+	read.GET("/logs/stream", s.apiLogStreamHandler)
+	read.GET("/backups", s.apiBackupsHandler)
+	read.GET("/checkpoints", s.apiCheckpointsHandler)
+
+	admin := api.Group("", s.authorize(config.RoleAdmin))
+	admin.POST("/service/start", s.apiServiceHandler(s.neo4j.Start, "Service successfully dispatched for starting"))
+	admin.POST("/service/stop", s.apiServiceHandler(s.neo4j.Stop, "Interrupt signal sent"))
+	admin.POST("/service/restart", s.apiServiceHandler(s.neo4j.Restart, "Service successfully dispatched for restart"))
+	admin.POST("/migrations", s.apiMigrationHandler(false))
+	admin.DELETE("/data", s.apiMigrationHandler(true))
+	admin.POST("/tenants/migrations", s.apiTenantsHandler)
+	admin.POST("/jobs/:id/cancel", s.apiCancelJobHandler)
+	admin.POST("/backups", s.apiCreateBackupHandler)
+	admin.DELETE("/backups", s.apiPruneBackupsHandler)
+	admin.POST("/backups/:name/restore", s.apiRestoreBackupHandler)
+	admin.DELETE("/backups/:name", s.apiDeleteBackupHandler)
+	admin.POST("/checkpoints", s.apiCreateCheckpointHandler)
+	admin.POST("/checkpoints/:name/restore", s.apiRestoreCheckpointHandler)
+	admin.DELETE("/checkpoints/:name", s.apiDeleteCheckpointHandler)
+}
+
+func (s *httpServer) apiServiceHandler(action func() error, msg string) func(*gin.Context) {
+	return func(c *gin.Context) {
+		s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+		if err := action(); err != nil {
+			s.apiError(c, err, nil)
+			return
+		}
+		state, _ := s.neo4j.State()
+		c.JSON(http.StatusOK, gin.H{"msg": msg, "neo4j_state": state})
+	}
+}
+
+// apiMigrationHandler starts migration job. With clean the database is wiped out first.
+func (s *httpServer) apiMigrationHandler(clean bool) func(*gin.Context) {
+	return func(c *gin.Context) {
+		s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+		opts, err := s.parseMigrationRequest(c)
+		if err != nil {
+			s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+			return
+		}
+		opts.Clean = clean
+		if clean && !opts.DryRun {
+			// Rollbacks are known only after planning, so they fail the job instead.
+			if err = s.neo4j.checkCleanProtection(opts.Batch, opts.Confirm); err != nil {
+				s.apiError(c, err, nil)
+				return
+			}
+		}
+		if !s.ensureRunning(c) {
+			return
+		}
+
+		kind := jobKindMigrate
+		if clean {
+			kind = jobKindClean
+		}
+		job, err := s.jobs.start(s.neo4j.context, newJob(kind, opts), func(
+			ctx context.Context,
+			progress migrator.ProgressNotifier,
+		) (string, any, error) {
+			opts.Progress = progress
+			steps, err := s.neo4j.MigrateContext(ctx, opts)
+			switch {
+			case opts.DryRun && steps != nil:
+				return steps.String(), nil, err
+			case steps == nil || steps.IsEmpty():
+				return "", nil, err
+			}
+			return "", s.neo4j.LastReport(), err
+		})
+		if err != nil {
+			s.apiError(c, err, nil)
+			return
+		}
+		s.sendJob(c, job)
+	}
+}
+
+func (s *httpServer) apiTenantsHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	opts, err := s.parseMigrationRequest(c)
+	if err == nil && (opts.DryRun || opts.Retry != nil) {
+		err = errors.New("dry_run and retry are not supported for tenants")
+	}
+	if err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return
+	}
+	if !s.ensureRunning(c) {
+		return
+	}
+
+	job, err := s.jobs.start(s.neo4j.context, newJob(jobKindTenants, opts), func(
+		ctx context.Context,
+		_ migrator.ProgressNotifier,
+	) (string, any, error) {
+		report, err := s.neo4j.MigrateTenantsContext(ctx, opts)
+		if report == nil {
+			return "", nil, err
+		}
+		return "", report, err
+	})
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	s.sendJob(c, job)
+}
+
+func (s *httpServer) apiJobsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": s.jobs.list()})
+}
+
+func (s *httpServer) apiJobHandler(c *gin.Context) {
+	job, ok := s.jobs.get(c.Param("id"))
+	if !ok {
+		s.apiAbort(c, http.StatusNotFound, errCodeNotFound, fmt.Errorf("job '%s' not found", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (s *httpServer) apiCancelJobHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	id := c.Param("id")
+	if _, ok := s.jobs.get(id); !ok {
+		s.apiAbort(c, http.StatusNotFound, errCodeNotFound, fmt.Errorf("job '%s' not found", id))
+		return
+	}
+	job, ok := s.jobs.cancel(id)
+	if !ok {
+		s.apiAbort(c, http.StatusConflict, errCodeConflict, fmt.Errorf("job '%s' is not running", id))
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ensureRunning sends conflict error, when Neo4j is not running, so job is not created at all.
+func (s *httpServer) ensureRunning(c *gin.Context) bool {
+	if state, err := s.neo4j.State(); err != nil || state != Running {
+		s.apiAbort(c, http.StatusConflict, errCodeConflict,
+			fmt.Errorf("cannot run import when service is '%s', must be running", state))
+		return false
+	}
+	return true
+}
+
+func (*httpServer) sendJob(c *gin.Context, job Job) {
+	c.Header("Location", apiV1Prefix+"/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+func newJob(kind string, opts MigrateOptions) Job {
+	job := Job{Kind: kind, Batch: string(opts.Batch), DryRun: opts.DryRun}
+	if opts.Target != nil {
+		job.Target = opts.Target.String()
+	}
+	return job
+}
+
+func (s *httpServer) apiVersionHandler(c *gin.Context) {
+	model, err := s.fetchVersion(c)
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, model)
+}
+
+func (s *httpServer) apiReportHandler(c *gin.Context) {
+	report := s.neo4j.LastReport()
+	if report == nil {
+		s.apiAbort(c, http.StatusNotFound, errCodeNotFound, errors.New("no migration was executed yet"))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (s *httpServer) parseMigrationRequest(c *gin.Context) (MigrateOptions, error) {
+	req := migrationRequest{}
+	// Body is optional, so empty body is the same as empty JSON object.
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return MigrateOptions{}, fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	opts := MigrateOptions{
+		Target:  s.defaultTargetVersion,
+		Batch:   s.defaultBatch,
+		DryRun:  req.DryRun,
+		Confirm: req.ConfirmToken,
+	}
+	if req.TargetVersion != "" {
+		target, err := migrator.ParseTargetVersion(req.TargetVersion)
+		if err != nil {
+			return opts, fmt.Errorf("target_version: %w", err)
+		}
+		opts.Target = target
+	}
+	batch, err := s.parseBatch(req.Batch)
+	if err != nil {
+		return opts, err
+	}
+	opts.Batch = batch
+	if req.Retry != nil {
+		retry, err := req.Retry.policy(s.neo4j.cfg.Planner.Retry)
+		if err != nil {
+			return opts, fmt.Errorf("retry: %w", err)
+		}
+		opts.Retry = retry
+	}
+	return opts, nil
+}
+
+// parseBatch returns default batch for empty name, or error when the batch is not defined.
+func (s *httpServer) parseBatch(name string) (migrator.Batch, error) {
+	if name == "" {
+		return s.defaultBatch, nil
+	}
+	if _, ok := s.neo4j.cfg.Planner.Batches[name]; !ok && name != config.DefaultInitialBatch {
+		return "", fmt.Errorf("batch '%s' is not defined", name)
+	}
+	return migrator.Batch(name), nil
+}
+
+// policy returns copy of base policy with values from the request.
+func (r *retryRequest) policy(base *config.RetryPolicy) (*config.RetryPolicy, error) {
+	policy := *base
+	if r.MaxAttempts != 0 {
+		policy.MaxAttempts = r.MaxAttempts
+	}
+	var err error
+	if policy.InitialBackoff, err = parseDurationOr(r.InitialBackoff, policy.InitialBackoff); err != nil {
+		return nil, fmt.Errorf("initial_backoff: %w", err)
+	}
+	if policy.MaxBackoff, err = parseDurationOr(r.MaxBackoff, policy.MaxBackoff); err != nil {
+		return nil, fmt.Errorf("max_backoff: %w", err)
+	}
+	if r.RetryableCodes != nil {
+		policy.RetryableCodes = r.RetryableCodes
+	}
+	if err = policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func parseDurationOr(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// apiError sends error with status according to the error type. Response can contain other keys, if not nil.
+func (s *httpServer) apiError(c *gin.Context, err error, resp gin.H) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
+
+	var stateErr *StateError
+	status, code := http.StatusInternalServerError, errCodeInternal
+	switch {
+	case errors.As(err, &stateErr):
+		status, code = http.StatusConflict, errCodeConflict
+	case errors.Is(err, migrator.ErrProtected):
+		status, code = http.StatusForbidden, errCodeForbidden
+	case errors.Is(err, errShuttingDown):
+		status, code = http.StatusServiceUnavailable, errCodeUnavailable
+	}
+	if resp == nil {
+		resp = gin.H{}
+	}
+	resp["error"] = apiError{Status: status, Code: code, Message: err.Error()}
+	c.JSON(status, resp)
+}
+
+func (*httpServer) apiAbort(c *gin.Context, status int, code string, err error) {
+	c.JSON(status, gin.H{"error": apiError{Status: status, Code: code, Message: err.Error()}})
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+const authRealm = "neo4j-graph-tool"
+
+// authorize returns middleware, which allows only requests with credentials of given role, or admin.
+// All requests are allowed, when auth is not configured.
+func (s *httpServer) authorize(role string) gin.HandlerFunc {
+	auth := s.neo4j.cfg.Supervisor.Auth
+	if !auth.Enabled() {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		granted, ok := authenticate(auth, c.Request)
+		switch {
+		case !ok:
+			s.httpLog.WithField("req", c.Request.URL.Path).WithField("remote", c.ClientIP()).
+				Warn("Request with missing or invalid credentials")
+			if len(auth.Users) > 0 {
+				c.Writer.Header().Add("WWW-Authenticate", `Basic realm="`+authRealm+`"`)
+			}
+			if len(auth.Tokens) > 0 {
+				c.Writer.Header().Add("WWW-Authenticate", `Bearer realm="`+authRealm+`"`)
+			}
+			s.authAbort(c, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized")
+		case granted != role && granted != config.RoleAdmin:
+			s.httpLog.WithField("req", c.Request.URL.Path).WithField("remote", c.ClientIP()).
+				Warnf("Request requires role '%s', but has '%s'", role, granted)
+			s.authAbort(c, http.StatusForbidden, errCodeForbidden, "Forbidden")
+		default:
+			c.Next()
+		}
+	}
+}
+
+// authenticate returns role of the bearer token or basic auth user from the request.
+// All credentials are always compared to not reveal which one matched by timing.
+func authenticate(auth *config.SupervisorAuth, r *http.Request) (string, bool) {
+	role, found := "", false
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		for _, t := range auth.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+				role, found = t.Role, true
+			}
+		}
+		return role, found
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	for _, u := range auth.Users {
+		userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(u.Username))
+		passMatch := subtle.ConstantTimeCompare([]byte(password), []byte(u.Password))
+		if userMatch&passMatch == 1 {
+			role, found = u.Role, true
+		}
+	}
+	return role, found
+}
+
+// authAbort stops the request with error in the format of /api/v1, or of legacy routes.
+func (s *httpServer) authAbort(c *gin.Context, status int, code, msg string) {
+	c.Abort()
+	if strings.HasPrefix(c.Request.URL.Path, apiV1Prefix) {
+		s.apiAbort(c, status, code, errors.New(strings.ToLower(msg)))
+		return
+	}
+	c.JSON(status, gin.H{"status": status, "error": msg})
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorization", func() {
+	var (
+		auth   *config.SupervisorAuth
+		engine *gin.Engine
+	)
+
+	BeforeEach(func() {
+		auth = &config.SupervisorAuth{
+			Tokens: []*config.AuthToken{
+				{Token: "read-token", Role: config.RoleRead},
+				{Token: "admin-token", Role: config.RoleAdmin},
+			},
+			Users: []*config.AuthUser{
+				{Username: "viewer", Password: "viewer-pass", Role: config.RoleRead},
+				{Username: "operator", Password: "operator-pass", Role: config.RoleAdmin},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		gin.SetMode(gin.TestMode)
+		s := &httpServer{
+			neo4j:   &Neo4jWrapper{cfg: &config.Config{Supervisor: &config.Supervisor{Auth: auth}}},
+			httpLog: testLogger(),
+		}
+		engine = gin.New()
+		ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"msg": "ok"}) }
+		engine.GET(apiV1Prefix+"/status", s.authorize(config.RoleRead), ok)
+		engine.POST(apiV1Prefix+"/migrations", s.authorize(config.RoleAdmin), ok)
+		engine.GET("/refresh-data", s.authorize(config.RoleAdmin), ok)
+	})
+
+	send := func(method, path string, setAuth func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, http.NoBody)
+		if setAuth != nil {
+			setAuth(req)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+	bearer := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	basic := func(username, password string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(username, password) }
+	}
+	apiErr := func(rec *httptest.ResponseRecorder) apiError {
+		resp := struct {
+			Error apiError `json:"error"`
+		}{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &resp)).To(Succeed())
+		return resp.Error
+	}
+
+	DescribeTable("Role access",
+		func(method, path string, setAuth func(*http.Request), status int) {
+			Expect(send(method, path, setAuth).Code).To(Equal(status))
+		},
+		Entry("Read token reads", http.MethodGet, "/api/v1/status", bearer("read-token"), http.StatusOK),
+		Entry("Admin token reads", http.MethodGet, "/api/v1/status", bearer("admin-token"), http.StatusOK),
+		Entry("Read user reads", http.MethodGet, "/api/v1/status", basic("viewer", "viewer-pass"), http.StatusOK),
+		Entry("Admin user reads", http.MethodGet, "/api/v1/status", basic("operator", "operator-pass"), http.StatusOK),
+		Entry("Read token cannot migrate", http.MethodPost, "/api/v1/migrations", bearer("read-token"),
+			http.StatusForbidden),
+		Entry("Read user cannot migrate", http.MethodPost, "/api/v1/migrations", basic("viewer", "viewer-pass"),
+			http.StatusForbidden),
+		Entry("Admin token migrates", http.MethodPost, "/api/v1/migrations", bearer("admin-token"), http.StatusOK),
+		Entry("Admin user migrates", http.MethodPost, "/api/v1/migrations", basic("operator", "operator-pass"),
+			http.StatusOK),
+		Entry("Missing credentials", http.MethodGet, "/api/v1/status", nil, http.StatusUnauthorized),
+		Entry("Invalid token", http.MethodGet, "/api/v1/status", bearer("unknown"), http.StatusUnauthorized),
+		Entry("Invalid password", http.MethodGet, "/api/v1/status", basic("viewer", "operator-pass"),
+			http.StatusUnauthorized),
+		Entry("Password of other user", http.MethodPost, "/api/v1/migrations", basic("viewer", "operator-pass"),
+			http.StatusUnauthorized),
+	)
+
+	It("Returns API error with challenges for all configured schemes", func() {
+		rec := send(http.MethodGet, "/api/v1/status", nil)
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(rec.Header().Values("WWW-Authenticate")).To(ConsistOf(
+			`Basic realm="neo4j-graph-tool"`, `Bearer realm="neo4j-graph-tool"`))
+		Expect(apiErr(rec)).To(Equal(apiError{
+			Status: http.StatusUnauthorized, Code: errCodeUnauthorized, Message: "unauthorized"}))
+
+		rec = send(http.MethodPost, "/api/v1/migrations", bearer("read-token"))
+		Expect(rec.Header().Values("WWW-Authenticate")).To(BeEmpty())
+		Expect(apiErr(rec)).To(Equal(apiError{
+			Status: http.StatusForbidden, Code: errCodeForbidden, Message: "forbidden"}))
+	})
+
+	It("Returns legacy error outside of API", func() {
+		rec := send(http.MethodGet, "/refresh-data", bearer("read-token"))
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(MatchJSON(`{"status": 403, "error": "Forbidden"}`))
+	})
+
+	When("only tokens are configured", func() {
+		BeforeEach(func() {
+			auth.Users = nil
+		})
+
+		It("Challenges only bearer token and refuses basic auth", func() {
+			rec := send(http.MethodGet, "/api/v1/status", basic("viewer", "viewer-pass"))
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rec.Header().Values("WWW-Authenticate")).To(ConsistOf(`Bearer realm="neo4j-graph-tool"`))
+		})
+	})
+
+	When("auth is not configured", func() {
+		BeforeEach(func() {
+			auth = nil
+		})
+
+		It("Allows all requests", func() {
+			Expect(send(http.MethodPost, "/api/v1/migrations", nil).Code).To(Equal(http.StatusOK))
+			Expect(send(http.MethodGet, "/refresh-data", bearer("unknown")).Code).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,195 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+type (
+	// ProcessBackend runs Neo4j for Neo4jWrapper. Wrapper calls Start only when the previous process exited,
+	// and Signal and Wait only after Start. Readiness is checked by the wrapper over Bolt.
+	ProcessBackend interface {
+		// Start starts Neo4j and returns without waiting until it is ready. Output should be logged into log.
+		Start(log *logrus.Entry) error
+		// Signal sends the signal to running Neo4j. Interrupt is used for stopping.
+		Signal(sig os.Signal) error
+		// Wait blocks until Neo4j exits and returns the exit error. It can be called more times with same result.
+		Wait() error
+		// State returns the state of the last started process.
+		State() ProcessState
+	}
+
+	// ProcessState describes the last started process. ExitCode is valid only when the process is not running.
+	ProcessState struct {
+		PID      int  `json:"pid,omitempty"`
+		Running  bool `json:"running"`
+		ExitCode int  `json:"exit_code"`
+	}
+
+	// CommandBackend runs Neo4j as command with arguments.
+	CommandBackend struct {
+		args []string
+
+		mu       sync.Mutex
+		cmd      *TSCmd
+		exited   bool
+		exitCode int
+	}
+
+	// ExternalBackend does not run anything, Neo4j is managed outside of the supervisor.
+	// Process is simulated, it is running from Start until Signal, so wrapper can connect to Neo4j and migrate it.
+	ExternalBackend struct {
+		mu      sync.Mutex
+		stopped chan struct{}
+	}
+)
+
+var errProcessNotStarted = errors.New("process is not started")
+
+// NewProcessBackend creates the backend selected in config. Docker backend is used when config is nil.
+func NewProcessBackend(cfg *config.Process) ProcessBackend {
+	switch {
+	case cfg == nil:
+		return NewDockerBackend()
+	case cfg.Backend == config.BackendBinary:
+		return NewCommandBackend(cfg.Neo4jBinary, "console")
+	case cfg.Backend == config.BackendExternal:
+		return &ExternalBackend{}
+	default:
+		return NewDockerBackend()
+	}
+}
+
+// NewDockerBackend runs entrypoint of the official Neo4j Docker image.
+func NewDockerBackend() *CommandBackend {
+	return NewCommandBackend(dockerEntryPointPath, "neo4j")
+}
+
+// NewCommandBackend runs the command, first element of args is the command itself.
+func NewCommandBackend(args ...string) *CommandBackend {
+	return &CommandBackend{args: args}
+}
+
+// Start implements ProcessBackend.
+func (b *CommandBackend) Start(log *logrus.Entry) error {
+	cmd, err := StartCmd(log, nil, b.args...)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cmd, b.exited, b.exitCode = cmd, false, 0
+	return nil
+}
+
+// Signal implements ProcessBackend.
+func (b *CommandBackend) Signal(sig os.Signal) error {
+	cmd := b.current()
+	if cmd == nil {
+		return errProcessNotStarted
+	}
+	return cmd.Process.Signal(sig)
+}
+
+// Wait implements ProcessBackend.
+func (b *CommandBackend) Wait() error {
+	cmd := b.current()
+	if cmd == nil {
+		return errProcessNotStarted
+	}
+	err := cmd.WaitTS()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Process might be started again already, do not overwrite its state.
+	if b.cmd == cmd {
+		b.exited, b.exitCode = true, cmd.ProcessState.ExitCode()
+	}
+	return err
+}
+
+// State implements ProcessBackend.
+func (b *CommandBackend) State() ProcessState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cmd == nil {
+		return ProcessState{}
+	}
+	return ProcessState{PID: b.cmd.Process.Pid, Running: !b.exited, ExitCode: b.exitCode}
+}
+
+func (b *CommandBackend) current() *TSCmd {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cmd
+}
+
+// Start implements ProcessBackend.
+func (b *ExternalBackend) Start(log *logrus.Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = make(chan struct{})
+	log.Info("Neo4j is managed externally, supervisor only connects to it")
+	return nil
+}
+
+// Signal implements ProcessBackend. Any signal stops simulated process, but external Neo4j keeps running.
+func (b *ExternalBackend) Signal(os.Signal) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped == nil {
+		return errProcessNotStarted
+	}
+	select {
+	case <-b.stopped:
+	default:
+		close(b.stopped)
+	}
+	return nil
+}
+
+// Wait implements ProcessBackend.
+func (b *ExternalBackend) Wait() error {
+	b.mu.Lock()
+	stopped := b.stopped
+	b.mu.Unlock()
+	if stopped == nil {
+		return errProcessNotStarted
+	}
+	<-stopped
+	return nil
+}
+
+// State implements ProcessBackend.
+func (b *ExternalBackend) State() ProcessState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped == nil {
+		return ProcessState{}
+	}
+	select {
+	case <-b.stopped:
+		return ProcessState{}
+	default:
+		return ProcessState{Running: true}
+	}
+}
@@ -0,0 +1,473 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+)
+
+const (
+	backupDumpExt        = ".dump"
+	backupTimeFormat     = "20060102-150405"
+	autoBackupNamePrefix = "auto-"
+)
+
+var backupNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,99}$`)
+
+// ErrBackupNotFound is returned, when backup with given name does not exist.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// BackupInfo describes single dump in the backup directory.
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Database  string    `json:"database"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// backupConfig returns configured backup. Without supervisor config, defaults are used.
+func (w *Neo4jWrapper) backupConfig() *config.Backup {
+	if w.cfg.Supervisor == nil || w.cfg.Supervisor.Backup == nil {
+		return &config.Backup{Dir: config.DefaultBackupDir, Neo4jAdmin: config.DefaultNeo4jAdmin}
+	}
+	return w.cfg.Supervisor.Backup
+}
+
+// backupDatabase returns database dumped by backup, the connection one by default.
+func (w *Neo4jWrapper) backupDatabase() string {
+	if db := w.backupConfig().Database; db != "" {
+		return db
+	}
+	return w.cfg.Connection.Database
+}
+
+// Backup dumps the database into the backup directory, see BackupContext.
+func (w *Neo4jWrapper) Backup(name string) (*BackupInfo, error) {
+	return w.BackupContext(w.context, name)
+}
+
+// BackupContext stops Neo4j, dumps the database with neo4j-admin under given name and starts Neo4j again,
+// when it was running. Empty name is generated from the current time.
+func (w *Neo4jWrapper) BackupContext(ctx context.Context, name string) (*BackupInfo, error) {
+	if name == "" {
+		name = "backup-" + time.Now().UTC().Format(backupTimeFormat)
+	}
+	if err := validateBackupName(name); err != nil {
+		return nil, err
+	}
+	dir := w.backupPath(name)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("backup '%s' already exists", name)
+	}
+
+	b := w.backupConfig()
+	db := w.backupDatabase()
+	err := w.maintenance(ctx, "backup", func() error {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return err
+		}
+		err := w.runUtility(ctx, &migrator.Process{
+			Args: []string{b.Neo4jAdmin, "database", "dump", db, "--to-path=" + dir},
+		})
+		if err != nil {
+			_ = os.RemoveAll(dir)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backup '%s' failed: %w", name, err)
+	}
+	w.log.WithField("backup", name).WithField("database", db).Info("Backup created")
+	return w.backupInfo(name)
+}
+
+// Restore loads the backup into the database, see RestoreContext.
+func (w *Neo4jWrapper) Restore(name, confirm string) (*BackupInfo, error) {
+	return w.RestoreContext(w.context, name, confirm)
+}
+
+// RestoreContext stops Neo4j, loads the backup with neo4j-admin and starts Neo4j again, when it was running.
+// The backup is loaded into the same database it was dumped from, current data are overwritten.
+// Restore of protected database requires confirmation token, see migrator.ErrProtected.
+func (w *Neo4jWrapper) RestoreContext(ctx context.Context, name, confirm string) (*BackupInfo, error) {
+	if err := validateBackupName(name); err != nil {
+		return nil, err
+	}
+	info, err := w.backupInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = w.checkRestoreProtection(info.Database, confirm); err != nil {
+		return nil, err
+	}
+
+	err = w.maintenance(ctx, "restore", func() error {
+		return w.runUtility(ctx, &migrator.Process{Args: []string{
+			w.backupConfig().Neo4jAdmin, "database", "load", info.Database,
+			"--from-path=" + w.backupPath(name), "--overwrite-destination=true",
+		}})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore of '%s' failed: %w", name, err)
+	}
+	w.log.WithField("backup", name).WithField("database", info.Database).Info("Backup restored")
+	return info, nil
+}
+
+// ListBackups returns all backups, the newest first. Checkpoints are not included.
+func (w *Neo4jWrapper) ListBackups() ([]BackupInfo, error) {
+	dumps, err := w.listDumps()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(dumps, func(info BackupInfo) bool {
+		return w.isCheckpoint(info.Name)
+	}), nil
+}
+
+// listDumps returns all backups and checkpoints, the newest first. Missing backup directory means no backups.
+func (w *Neo4jWrapper) listDumps() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(w.backupConfig().Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !backupNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := w.backupInfo(entry.Name())
+		if errors.Is(err, ErrBackupNotFound) {
+			// Unrelated directory, or dump which is still being created.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, *info)
+	}
+	slices.SortFunc(backups, func(a, b BackupInfo) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return backups, nil
+}
+
+// DeleteBackup removes the backup from the backup directory.
+func (w *Neo4jWrapper) DeleteBackup(name string) error {
+	if err := validateBackupName(name); err != nil {
+		return err
+	}
+	if _, err := w.backupInfo(name); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(w.backupPath(name)); err != nil {
+		return err
+	}
+	w.log.WithField("backup", name).Info("Backup deleted")
+	return nil
+}
+
+// PruneBackups deletes all backups except the newest keep ones and returns deleted backups.
+// Checkpoints are never pruned.
+func (w *Neo4jWrapper) PruneBackups(keep int) ([]BackupInfo, error) {
+	return w.pruneBackups(keep, "")
+}
+
+// pruneBackups is like PruneBackups, but only backups with the name prefix are considered.
+func (w *Neo4jWrapper) pruneBackups(keep int, prefix string) ([]BackupInfo, error) {
+	if keep < 0 {
+		return nil, errors.New("keep cannot be negative")
+	}
+	backups, err := w.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	deleted := []BackupInfo{}
+	for _, info := range backups {
+		if !strings.HasPrefix(info.Name, prefix) {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		if err = w.DeleteBackup(info.Name); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, info)
+	}
+	return deleted, nil
+}
+
+// backupBeforeClean creates automatic backup and prunes old automatic backups according to config.
+func (w *Neo4jWrapper) backupBeforeClean(ctx context.Context) error {
+	if _, err := w.BackupContext(ctx, autoBackupNamePrefix+time.Now().UTC().Format(backupTimeFormat)); err != nil {
+		return err
+	}
+	if keep := w.backupConfig().Keep; keep > 0 {
+		if _, err := w.pruneBackups(keep, autoBackupNamePrefix); err != nil {
+			w.log.Warnf("Cannot prune automatic backups: %v", err)
+		}
+	}
+	return nil
+}
+
+// maintenance runs the action with stopped Neo4j in Maintenance state. Running Neo4j is stopped first
+// and started again after the action, even when the action failed. Shutdown waits for running maintenance.
+func (w *Neo4jWrapper) maintenance(ctx context.Context, operation string, action func() error) error {
+	if _, ok := w.process.(*ExternalBackend); ok {
+		return fmt.Errorf("%s is not supported, when Neo4j is managed externally", operation)
+	}
+	if err := w.beginMaintenance(); err != nil {
+		return err
+	}
+	defer w.migrations.Done()
+	state, err := w.State()
+	if err != nil {
+		return err
+	}
+	wasRunning := state == Running
+	if wasRunning {
+		w.log.Debugf("Stopping Neo4j for %s", operation)
+		if err = w.Stop(); err != nil {
+			return err
+		}
+		if err = w.Wait(); err != nil {
+			return err
+		}
+	}
+	if err = w.setMaintenanceState(operation); err != nil {
+		return err
+	}
+
+	actionErr := action()
+
+	if err = serviceSem.Acquire(context.WithoutCancel(ctx), 1); err != nil {
+		return errors.Join(actionErr, err)
+	}
+	w.serviceState = Stopped
+	// Neo4j is not started again during shutdown, it would be stopped right away.
+	wasRunning = wasRunning && !w.isShuttingDown()
+	if wasRunning {
+		err = w.start()
+	}
+	serviceSem.Release(1)
+	if err == nil && wasRunning {
+		err = w.WaitForNeo4j()
+	}
+	return errors.Join(actionErr, err)
+}
+
+func (w *Neo4jWrapper) setMaintenanceState(operation string) error {
+	if err := serviceSem.Acquire(w.context, 1); err != nil {
+		return err
+	}
+	defer serviceSem.Release(1)
+	if w.serviceState != Stopped {
+		return stateErrorf("cannot run %s when service is '%s', must be running or stopped", operation, w.serviceState)
+	}
+	w.serviceState = Maintenance
+	return nil
+}
+
+// backupInfo reads details of the backup. Directory must contain exactly one dump.
+func (w *Neo4jWrapper) backupInfo(name string) (*BackupInfo, error) {
+	dumps, err := filepath.Glob(filepath.Join(w.backupPath(name), "*"+backupDumpExt))
+	if err != nil {
+		return nil, err
+	}
+	if len(dumps) != 1 {
+		return nil, fmt.Errorf("%w: '%s'", ErrBackupNotFound, name)
+	}
+	stat, err := os.Stat(dumps[0])
+	if err != nil {
+		return nil, err
+	}
+	return &BackupInfo{
+		Name:      name,
+		Database:  strings.TrimSuffix(filepath.Base(dumps[0]), backupDumpExt),
+		CreatedAt: stat.ModTime(),
+		Size:      stat.Size(),
+	}, nil
+}
+
+func (w *Neo4jWrapper) backupPath(name string) string {
+	return filepath.Join(w.backupConfig().Dir, name)
+}
+
+func validateBackupName(name string) error {
+	if !backupNamePattern.MatchString(name) {
+		return fmt.Errorf("backup name '%s' is invalid, only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	return nil
+}
+
+func (s *httpServer) apiBackupsHandler(c *gin.Context) {
+	backups, err := s.neo4j.ListBackups()
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+func (s *httpServer) apiCreateBackupHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	req := backupRequest{}
+	// Body is optional, so empty body is the same as empty JSON object.
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return
+	}
+	if req.Name != "" {
+		if err := validateBackupName(req.Name); err != nil {
+			s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+			return
+		}
+	}
+
+	job, err := s.jobs.start(s.neo4j.context, Job{Kind: jobKindBackup}, func(
+		ctx context.Context,
+		_ migrator.ProgressNotifier,
+	) (string, any, error) {
+		info, err := s.neo4j.BackupContext(ctx, req.Name)
+		if info == nil {
+			return "", nil, err
+		}
+		return "", info, err
+	})
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	s.sendJob(c, job)
+}
+
+func (s *httpServer) apiRestoreBackupHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	name := c.Param("name")
+	if !s.ensureBackup(c, name) {
+		return
+	}
+	confirm, ok := s.parseRestoreRequest(c)
+	if !ok {
+		return
+	}
+	info, err := s.neo4j.backupInfo(name)
+	if err == nil {
+		err = s.neo4j.checkRestoreProtection(info.Database, confirm)
+	}
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+
+	job, err := s.jobs.start(s.neo4j.context, Job{Kind: jobKindRestore}, func(
+		ctx context.Context,
+		_ migrator.ProgressNotifier,
+	) (string, any, error) {
+		info, err := s.neo4j.RestoreContext(ctx, name, confirm)
+		if info == nil {
+			return "", nil, err
+		}
+		return "", info, err
+	})
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	s.sendJob(c, job)
+}
+
+func (s *httpServer) apiDeleteBackupHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	name := c.Param("name")
+	if !s.ensureBackup(c, name) {
+		return
+	}
+	if err := s.neo4j.DeleteBackup(name); err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "Backup deleted"})
+}
+
+func (s *httpServer) apiPruneBackupsHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	keep, err := strconv.Atoi(c.Query("keep"))
+	if err != nil || keep < 0 {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest,
+			errors.New("query parameter keep must be non-negative number"))
+		return
+	}
+	deleted, err := s.neo4j.PruneBackups(keep)
+	if err != nil {
+		s.apiError(c, err, gin.H{"deleted": deleted})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// parseRestoreRequest returns confirmation token from optional JSON body.
+// Sends bad request error and returns false, when the body is invalid.
+func (s *httpServer) parseRestoreRequest(c *gin.Context) (string, bool) {
+	req := restoreRequest{}
+	// Body is optional, so empty body is the same as empty JSON object.
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return "", false
+	}
+	return req.ConfirmToken, true
+}
+
+// ensureBackup sends bad request or not found error, when the backup cannot be used.
+func (s *httpServer) ensureBackup(c *gin.Context, name string) bool {
+	if err := validateBackupName(name); err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return false
+	}
+	if _, err := s.neo4j.backupInfo(name); errors.Is(err, ErrBackupNotFound) {
+		s.apiAbort(c, http.StatusNotFound, errCodeNotFound, err)
+		return false
+	} else if err != nil {
+		s.apiError(c, err, nil)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Restore of protected database", func() {
+	var (
+		w       *Neo4jWrapper
+		backend *fakeBackend
+		s       *httpServer
+		engine  *gin.Engine
+	)
+
+	// writeDump creates backup of the database, checkpoint when batch is set.
+	writeDump := func(name, database, batch string) {
+		dir := w.backupPath(name)
+		Expect(os.MkdirAll(dir, 0o750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, database+backupDumpExt), []byte("dump"), 0o600)).To(Succeed())
+		if batch == "" {
+			return
+		}
+		fingerprint, err := w.migrationsFingerprint()
+		Expect(err).To(Succeed())
+		data, err := json.Marshal(checkpointMeta{Batch: batch, Fingerprint: fingerprint})
+		Expect(err).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, checkpointFile), data, 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		w, backend = newTestWrapper(context.Background(), func(cfg *config.Config) {
+			cfg.Planner.ConfirmToken = "staging"
+			cfg.Planner.Batches["seed"].Protected = true
+		})
+		s, engine = newTestServer(w)
+		writeDump("nightly", "audit", "")
+		writeDump("other", "other", "")
+		writeDump("seeded", "other", "seed")
+	})
+
+	restore := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+	jobState := func(rec *httptest.ResponseRecorder) func() JobState {
+		job := Job{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &job)).To(Succeed())
+		return func() JobState {
+			j, _ := s.jobs.get(job.ID)
+			return j.State
+		}
+	}
+
+	It("Refuses restore without confirmation token", func() {
+		_, err := w.RestoreContext(context.Background(), "nightly", "")
+		Expect(err).To(MatchError(migrator.ErrProtected))
+		Expect(err).To(MatchError("batch is protected, restore of database 'audit' requires confirmation token"))
+		_, err = w.RestoreContext(context.Background(), "nightly", "invalid")
+		Expect(err).To(MatchError(migrator.ErrProtected))
+		Expect(backend.startCount()).To(BeZero())
+
+		info, err := w.RestoreContext(context.Background(), "nightly", "staging")
+		Expect(err).To(Succeed())
+		Expect(info.Database).To(Equal("audit"))
+		Expect(w.State()).To(Equal(Stopped))
+
+		_, err = w.RestoreContext(context.Background(), "other", "")
+		Expect(err).To(Succeed(), "database not used by protected batch")
+	})
+
+	It("Refuses restore of checkpoint of protected batch without confirmation token", func() {
+		_, err := w.RestoreCheckpointContext(context.Background(), "seeded", "")
+		Expect(err).To(MatchError(migrator.ErrProtected))
+		Expect(err).To(MatchError("batch is protected, wiping out of batch 'seed' requires confirmation token"))
+
+		cp, err := w.RestoreCheckpointContext(context.Background(), "seeded", "staging")
+		Expect(err).To(Succeed())
+		Expect(cp.Batch).To(Equal("seed"))
+	})
+
+	It("Refuses restore request before the job is started", func() {
+		rec := restore("/api/v1/backups/nightly/restore", "")
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(MatchJSON(`{"error": {"status": 403, "code": "forbidden",
+			"message": "batch is protected, restore of database 'audit' requires confirmation token"}}`))
+
+		rec = restore("/api/v1/backups/nightly/restore", `{"confirm_token": "invalid"}`)
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		rec = restore("/api/v1/checkpoints/seeded/restore", "")
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		rec = restore("/api/v1/backups/nightly/restore", `{"confirm": "staging"}`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(s.jobs.list()).To(BeEmpty())
+	})
+
+	It("Restores protected database with confirmation token", func() {
+		rec := restore("/api/v1/backups/nightly/restore", `{"confirm_token": "staging"}`)
+		Expect(rec.Code).To(Equal(http.StatusAccepted))
+		Eventually(jobState(rec)).Should(Equal(JobSucceeded))
+
+		rec = restore("/api/v1/checkpoints/seeded/restore", `{"confirm_token": "staging"}`)
+		Expect(rec.Code).To(Equal(http.StatusAccepted))
+		Eventually(jobState(rec)).Should(Equal(JobSucceeded))
+
+		rec = restore("/api/v1/backups/other/restore", "")
+		Expect(rec.Code).To(Equal(http.StatusAccepted))
+		Eventually(jobState(rec)).Should(Equal(JobSucceeded))
+	})
+
+	It("Does not log query parameters of requests", func() {
+		logger, hook := test.NewNullLogger()
+		logger.SetLevel(logrus.DebugLevel)
+		s.httpLog = logger.WithField(componentLogKey, "http")
+
+		restore("/api/v1/backups/nightly/restore?confirm=staging", "")
+		Expect(hook.AllEntries()).NotTo(BeEmpty())
+		for _, entry := range hook.AllEntries() {
+			Expect(entry.Data).To(HaveKeyWithValue("req", "/api/v1/backups/nightly/restore"))
+		}
+	})
+})
@@ -0,0 +1,393 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+)
+
+// checkpointFile is stored next to the dump and marks the backup as checkpoint.
+const checkpointFile = "checkpoint.json"
+
+type (
+	// Checkpoint is backup with recorded migration state, so tests can reset the database without migrating.
+	// Valid is false, when local migration files changed since the checkpoint was created.
+	Checkpoint struct {
+		BackupInfo
+		Batch       string                 `json:"batch"`
+		Model       migrator.DatabaseModel `json:"model"`
+		Fingerprint string                 `json:"fingerprint"`
+		Valid       bool                   `json:"valid"`
+	}
+
+	// checkpointMeta is content of checkpointFile.
+	checkpointMeta struct {
+		Batch       string                 `json:"batch"`
+		Model       migrator.DatabaseModel `json:"model"`
+		Fingerprint string                 `json:"fingerprint"`
+	}
+
+	// checkpointRequest is JSON body of checkpoint creation. Default batch is used when empty.
+	checkpointRequest struct {
+		Name  string `json:"name"`
+		Batch string `json:"batch"`
+	}
+)
+
+// ErrCheckpointStale is returned, when checkpoint cannot be restored, because migration files changed.
+var ErrCheckpointStale = errors.New("checkpoint is stale, local migration files changed")
+
+// CreateCheckpoint creates the checkpoint, see CreateCheckpointContext.
+func (w *Neo4jWrapper) CreateCheckpoint(name string, batch migrator.Batch) (*Checkpoint, error) {
+	return w.CreateCheckpointContext(w.context, name, batch)
+}
+
+// CreateCheckpointContext records applied versions and fingerprint of local migration files
+// and backs up the database under the name. Neo4j must be running, it is restarted during the backup.
+// Only one database is backed up, so all folders of the batch must be stored in it.
+func (w *Neo4jWrapper) CreateCheckpointContext(
+	ctx context.Context,
+	name string,
+	batch migrator.Batch,
+) (*Checkpoint, error) {
+	if err := validateBackupName(name); err != nil {
+		return nil, err
+	}
+	if state, err := w.State(); err != nil {
+		return nil, err
+	} else if state != Running {
+		return nil, stateErrorf("cannot create checkpoint when service is '%s', must be running", state)
+	}
+
+	p, err := migrator.NewPlanner(w.cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := w.checkpointDatabase(p, batch)
+	if err != nil {
+		return nil, err
+	}
+	models, err := p.Versions(ctx, migrator.NewSessionFactory(w.driver, w.cfg.Connection, neo4j.AccessModeRead))
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := w.migrationsFingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute fingerprint of migration files: %w", err)
+	}
+	meta := checkpointMeta{Batch: string(batch), Model: models[db], Fingerprint: fingerprint}
+
+	info, err := w.BackupContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(w.backupPath(name), checkpointFile), data, 0o644) // #nosec G306
+	}
+	if err != nil {
+		_ = os.RemoveAll(w.backupPath(name))
+		return nil, fmt.Errorf("cannot write checkpoint '%s': %w", name, err)
+	}
+	w.log.WithField("checkpoint", name).WithField("batch", batch).Info("Checkpoint created")
+	return newCheckpoint(info, meta, fingerprint), nil
+}
+
+// RestoreCheckpoint restores the checkpoint, see RestoreCheckpointContext.
+func (w *Neo4jWrapper) RestoreCheckpoint(name, confirm string) (*Checkpoint, error) {
+	return w.RestoreCheckpointContext(w.context, name, confirm)
+}
+
+// RestoreCheckpointContext loads the checkpoint into the database like RestoreContext.
+// Returns ErrCheckpointStale, when local migration files changed since the checkpoint was created.
+// Checkpoint of protected batch requires confirmation token like clean migration.
+func (w *Neo4jWrapper) RestoreCheckpointContext(ctx context.Context, name, confirm string) (*Checkpoint, error) {
+	cp, err := w.GetCheckpoint(name)
+	if err != nil {
+		return nil, err
+	}
+	if !cp.Valid {
+		return nil, fmt.Errorf("%w: '%s'", ErrCheckpointStale, name)
+	}
+	if err = w.checkCleanProtection(migrator.Batch(cp.Batch), confirm); err != nil {
+		return nil, err
+	}
+	if _, err = w.RestoreContext(ctx, name, confirm); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// GetCheckpoint returns the checkpoint, or error wrapping ErrBackupNotFound, when it does not exist.
+func (w *Neo4jWrapper) GetCheckpoint(name string) (*Checkpoint, error) {
+	if err := validateBackupName(name); err != nil {
+		return nil, err
+	}
+	fingerprint, err := w.migrationsFingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute fingerprint of migration files: %w", err)
+	}
+	return w.readCheckpoint(name, fingerprint)
+}
+
+// ListCheckpoints returns all checkpoints, the newest first.
+func (w *Neo4jWrapper) ListCheckpoints() ([]Checkpoint, error) {
+	dumps, err := w.listDumps()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := w.migrationsFingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute fingerprint of migration files: %w", err)
+	}
+	checkpoints := []Checkpoint{}
+	for _, info := range dumps {
+		if !w.isCheckpoint(info.Name) {
+			continue
+		}
+		cp, err := w.readCheckpoint(info.Name, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, *cp)
+	}
+	return checkpoints, nil
+}
+
+// DeleteCheckpoint removes the checkpoint together with its dump.
+func (w *Neo4jWrapper) DeleteCheckpoint(name string) error {
+	if err := validateBackupName(name); err != nil {
+		return err
+	}
+	if !w.isCheckpoint(name) {
+		return fmt.Errorf("%w: '%s' is not checkpoint", ErrBackupNotFound, name)
+	}
+	return w.DeleteBackup(name)
+}
+
+func (w *Neo4jWrapper) readCheckpoint(name, fingerprint string) (*Checkpoint, error) {
+	info, err := w.backupInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(w.backupPath(name), checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: '%s' is not checkpoint", ErrBackupNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta := checkpointMeta{}
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint '%s': %w", name, err)
+	}
+	return newCheckpoint(info, meta, fingerprint), nil
+}
+
+// newCheckpoint creates checkpoint, which is valid, when it has the current fingerprint of migration files.
+func newCheckpoint(info *BackupInfo, meta checkpointMeta, fingerprint string) *Checkpoint {
+	return &Checkpoint{
+		BackupInfo:  *info,
+		Batch:       meta.Batch,
+		Model:       meta.Model,
+		Fingerprint: meta.Fingerprint,
+		Valid:       meta.Fingerprint == fingerprint,
+	}
+}
+
+// checkpointDatabase returns the database of the batch. Error is returned, when the batch uses more databases
+// or other database than the backed up one.
+func (w *Neo4jWrapper) checkpointDatabase(p *migrator.Planner, batch migrator.Batch) (string, error) {
+	db := w.backupDatabase()
+	if dbs := p.Databases(batch); len(dbs) != 1 || dbs[0] != db {
+		return "", fmt.Errorf("cannot create checkpoint of batch '%s' stored in databases '%s', "+
+			"only database '%s' is backed up", batch, strings.Join(dbs, "', '"), db)
+	}
+	return db, nil
+}
+
+func (w *Neo4jWrapper) isCheckpoint(name string) bool {
+	_, err := os.Stat(filepath.Join(w.backupPath(name), checkpointFile))
+	return err == nil
+}
+
+// migrationsFingerprint returns hash of paths and content of all files in the import dir.
+// Report file is skipped, as it changes with every migration.
+func (w *Neo4jWrapper) migrationsFingerprint() (string, error) {
+	root := w.getImportDir()
+	reportPath := ""
+	if w.cfg.Planner.ReportFile != "" {
+		reportPath = filepath.Clean(w.getReportPath())
+	}
+	hash := sha256.New()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || path == reportPath {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, _ = hash.Write([]byte(filepath.ToSlash(rel) + "\x00"))
+		_, err = io.Copy(hash, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *httpServer) apiCheckpointsHandler(c *gin.Context) {
+	checkpoints, err := s.neo4j.ListCheckpoints()
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"checkpoints": checkpoints})
+}
+
+func (s *httpServer) apiCreateCheckpointHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	req := checkpointRequest{}
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return
+	}
+	if err := validateBackupName(req.Name); err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return
+	}
+	batch, err := s.parseBatch(req.Batch)
+	if err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return
+	}
+	// config is validated in supervisor
+	p, _ := migrator.NewPlanner(s.neo4j.cfg)
+	if _, err = s.neo4j.checkpointDatabase(p, batch); err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return
+	}
+	if !s.ensureRunning(c) {
+		return
+	}
+
+	job, err := s.jobs.start(s.neo4j.context, Job{Kind: jobKindCheckpoint, Batch: string(batch)}, func(
+		ctx context.Context,
+		_ migrator.ProgressNotifier,
+	) (string, any, error) {
+		cp, err := s.neo4j.CreateCheckpointContext(ctx, req.Name, batch)
+		if cp == nil {
+			return "", nil, err
+		}
+		return "", cp, err
+	})
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	s.sendJob(c, job)
+}
+
+func (s *httpServer) apiRestoreCheckpointHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	name := c.Param("name")
+	if err := validateBackupName(name); err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return
+	}
+	cp, err := s.neo4j.GetCheckpoint(name)
+	switch {
+	case errors.Is(err, ErrBackupNotFound):
+		s.apiAbort(c, http.StatusNotFound, errCodeNotFound, err)
+		return
+	case err != nil:
+		s.apiError(c, err, nil)
+		return
+	case !cp.Valid:
+		s.apiAbort(c, http.StatusConflict, errCodeConflict, fmt.Errorf("%w: '%s'", ErrCheckpointStale, name))
+		return
+	}
+	confirm, ok := s.parseRestoreRequest(c)
+	if !ok {
+		return
+	}
+	err = s.neo4j.checkCleanProtection(migrator.Batch(cp.Batch), confirm)
+	if err == nil {
+		err = s.neo4j.checkRestoreProtection(cp.Database, confirm)
+	}
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+
+	job, err := s.jobs.start(s.neo4j.context, Job{Kind: jobKindRestore, Batch: cp.Batch}, func(
+		ctx context.Context,
+		_ migrator.ProgressNotifier,
+	) (string, any, error) {
+		cp, err := s.neo4j.RestoreCheckpointContext(ctx, name, confirm)
+		if cp == nil {
+			return "", nil, err
+		}
+		return "", cp, err
+	})
+	if err != nil {
+		s.apiError(c, err, nil)
+		return
+	}
+	s.sendJob(c, job)
+}
+
+func (s *httpServer) apiDeleteCheckpointHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	name := c.Param("name")
+	if err := validateBackupName(name); err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return
+	}
+	err := s.neo4j.DeleteCheckpoint(name)
+	switch {
+	case errors.Is(err, ErrBackupNotFound):
+		s.apiAbort(c, http.StatusNotFound, errCodeNotFound, err)
+	case err != nil:
+		s.apiError(c, err, nil)
+	default:
+		c.JSON(http.StatusOK, gin.H{"msg": "Checkpoint deleted"})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checkpoints", func() {
+	var (
+		w      *Neo4jWrapper
+		p      *migrator.Planner
+		engine *gin.Engine
+	)
+
+	BeforeEach(func() {
+		w, _ = newTestWrapper(context.Background(), nil)
+		_, engine = newTestServer(w)
+		var err error
+		p, err = migrator.NewPlanner(w.cfg)
+		Expect(err).To(Succeed())
+	})
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/checkpoints", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	It("Allows checkpoint of batch stored only in backed up database", func() {
+		Expect(w.checkpointDatabase(p, "schema")).To(Equal("neo4j"))
+	})
+
+	It("Refuses checkpoint of batch stored in more databases", func() {
+		_, err := w.checkpointDatabase(p, "seed")
+		Expect(err).To(MatchError("cannot create checkpoint of batch 'seed' stored in databases 'audit', 'neo4j', " +
+			"only database 'neo4j' is backed up"))
+	})
+
+	It("Refuses checkpoint of batch stored in other than backed up database", func() {
+		w.cfg.Supervisor.Backup.Database = "audit"
+		_, err := w.checkpointDatabase(p, "schema")
+		Expect(err).To(MatchError("cannot create checkpoint of batch 'schema' stored in databases 'neo4j', " +
+			"only database 'audit' is backed up"))
+	})
+
+	It("Refuses checkpoint request before the job is started", func() {
+		rec := create(`{"name": "seeded", "batch": "seed"}`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring("cannot create checkpoint of batch 'seed'"))
+
+		// Supported batch passes the check and fails only on stopped Neo4j.
+		rec = create(`{"name": "schema", "batch": "schema"}`)
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+	})
+})
@@ -0,0 +1,145 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthCheckTimeout limits all readiness checks together, so probes do not hang on unreachable DB.
+const healthCheckTimeout = 5 * time.Second
+
+// Results of single health check.
+const (
+	checkOK      = "ok"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+type (
+	healthCheck struct {
+		Status  string `json:"status"`
+		Message string `json:"message,omitempty"`
+	}
+
+	// healthReport is detailed health document of '/api/v1/health'.
+	healthReport struct {
+		Ready       bool                   `json:"ready"`
+		Checks      map[string]healthCheck `json:"checks"`
+		States      map[string]any         `json:"states"`
+		Process     ProcessState           `json:"process"`
+		RunningJobs int                    `json:"running_jobs"`
+		StartedAt   time.Time              `json:"started_at"`
+		Uptime      string                 `json:"uptime"`
+		LastReport  *lastReportSummary     `json:"last_migration,omitempty"`
+	}
+
+	lastReportSummary struct {
+		FinishedAt time.Time `json:"finished_at"`
+		Duration   string    `json:"duration"`
+		Steps      int       `json:"steps"`
+		Error      string    `json:"error,omitempty"`
+	}
+)
+
+// healthzHandler reports only that supervisor itself is alive, Neo4j state does not matter.
+func (*httpServer) healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": checkOK})
+}
+
+// readyzHandler reports if Neo4j is ready to serve requests.
+func (s *httpServer) readyzHandler(c *gin.Context) {
+	ready, checks := s.readiness(c.Request.Context())
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"ready": ready, "checks": checks})
+}
+
+// apiHealthHandler returns readiness checks together with states, jobs and the last migration.
+func (s *httpServer) apiHealthHandler(c *gin.Context) {
+	ready, checks := s.readiness(c.Request.Context())
+	report := healthReport{
+		Ready:       ready,
+		Checks:      checks,
+		States:      s.neo4j.AllStates(),
+		Process:     s.neo4j.process.State(),
+		RunningJobs: s.jobs.running(),
+		StartedAt:   s.startedAt,
+		Uptime:      time.Since(s.startedAt).Round(time.Second).String(),
+	}
+	if last := s.neo4j.LastReport(); last != nil {
+		report.LastReport = &lastReportSummary{
+			FinishedAt: last.FinishedAt,
+			Duration:   last.Duration.String(),
+			Steps:      len(last.Steps),
+			Error:      last.Error,
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// readiness runs all checks. Checks depending on the failed one are skipped.
+func (s *httpServer) readiness(ctx context.Context) (bool, map[string]healthCheck) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]healthCheck{
+		"neo4j":    {Status: checkOK},
+		"bolt":     {Status: checkSkipped},
+		"migrated": {Status: checkSkipped},
+	}
+	state, err := s.neo4j.State()
+	switch {
+	case err != nil:
+		checks["neo4j"] = healthCheck{Status: checkFailed, Message: err.Error()}
+		return false, checks
+	case state == Updating:
+		checks["neo4j"] = healthCheck{Status: checkFailed, Message: "migration is in progress"}
+		return false, checks
+	case state != Running:
+		checks["neo4j"] = healthCheck{Status: checkFailed, Message: fmt.Sprintf("service is '%s'", state)}
+		return false, checks
+	}
+
+	if err = s.neo4j.checkReady(ctx); err != nil {
+		checks["bolt"] = healthCheck{Status: checkFailed, Message: err.Error()}
+		return false, checks
+	}
+	checks["bolt"] = healthCheck{Status: checkOK}
+
+	if !s.neo4j.cfg.Supervisor.ReadyWhenMigrated {
+		return true, checks
+	}
+	pending, err := s.neo4j.pendingFiles(ctx, s.defaultTargetVersion, s.defaultBatch)
+	switch {
+	case err != nil:
+		checks["migrated"] = healthCheck{Status: checkFailed, Message: err.Error()}
+	case pending > 0:
+		checks["migrated"] = healthCheck{
+			Status:  checkFailed,
+			Message: fmt.Sprintf("%d migration files of batch '%s' are not applied", pending, s.defaultBatch),
+		}
+	default:
+		checks["migrated"] = healthCheck{Status: checkOK}
+	}
+	return checks["migrated"].Status == checkOK, checks
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+)
+
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCanceled  JobState = "canceled"
+	// JobInterrupted is set, when migration was interrupted by shutdown after its running step.
+	JobInterrupted JobState = "interrupted"
+)
+
+// Kinds of jobs.
+const (
+	jobKindMigrate = "migrate"
+	jobKindClean   = "clean"
+	jobKindTenants = "tenants"
+	jobKindBackup  = "backup"
+	jobKindRestore = "restore"
+	// jobKindCheckpoint creates checkpoint, restoring it is jobKindRestore.
+	jobKindCheckpoint = "checkpoint"
+)
+
+type (
+	// Job is migration started asynchronously from HTTP API. Job is always accessed as a copy, see jobManager.
+	Job struct {
+		ID         string      `json:"id"`
+		Kind       string      `json:"kind"`
+		State      JobState    `json:"state"`
+		Target     string      `json:"target_version,omitempty"`
+		Batch      string      `json:"batch"`
+		DryRun     bool        `json:"dry_run"`
+		CreatedAt  time.Time   `json:"created_at"`
+		FinishedAt *time.Time  `json:"finished_at,omitempty"`
+		Progress   JobProgress `json:"progress"`
+		// Plan is set for dry run only.
+		Plan string `json:"plan,omitempty"`
+		// Report is *migrator.ExecutionReport, *migrator.TenantsReport for tenants, *BackupInfo for backups,
+		// or *Checkpoint for checkpoints.
+		Report any    `json:"report,omitempty"`
+		Error  string `json:"error,omitempty"`
+
+		cancel context.CancelFunc
+	}
+
+	// JobProgress holds number of processed steps and currently executed file.
+	JobProgress struct {
+		StepsDone   int    `json:"steps_done"`
+		StepsTotal  int    `json:"steps_total"`
+		CurrentFile string `json:"current_file,omitempty"`
+	}
+
+	// jobManager keeps running jobs and bounded history of finished ones.
+	jobManager struct {
+		mu      sync.Mutex
+		jobs    map[string]*Job
+		order   []string
+		history int
+		// closed manager does not start new jobs.
+		closed bool
+	}
+
+	// jobFunc runs the job and fills its result. Progress can be updated with given function.
+	jobFunc func(ctx context.Context, progress migrator.ProgressNotifier) (plan string, report any, err error)
+)
+
+func newJobManager(history int) *jobManager {
+	return &jobManager{jobs: map[string]*Job{}, history: history}
+}
+
+// start creates the job and runs it in background with context derived from parent.
+// Error is returned, when the manager is closed.
+func (m *jobManager) start(parent context.Context, job Job, run jobFunc) (Job, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return Job{}, errShuttingDown
+	}
+	ctx, cancel := context.WithCancel(parent)
+	j := &job
+	j.ID = newJobID()
+	j.State = JobRunning
+	j.CreatedAt = time.Now()
+	j.cancel = cancel
+
+	m.jobs[j.ID] = j
+	m.order = append(m.order, j.ID)
+	m.prune()
+	snapshot := *j
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		plan, report, err := run(ctx, func(done, total int, mc *migrator.MigrationContext) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			j.Progress.StepsDone, j.Progress.StepsTotal = done, total
+			j.Progress.CurrentFile = ""
+			if mc != nil {
+				j.Progress.CurrentFile = mc.Path
+			}
+		})
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		now := time.Now()
+		j.FinishedAt = &now
+		j.Plan, j.Report = plan, report
+		j.Progress.CurrentFile = ""
+		switch {
+		case err == nil:
+			j.State = JobSucceeded
+			j.Progress.StepsDone = j.Progress.StepsTotal
+		case errors.Is(err, migrator.ErrInterrupted):
+			j.State, j.Error = JobInterrupted, err.Error()
+		case ctx.Err() != nil && parent.Err() == nil:
+			j.State, j.Error = JobCanceled, err.Error()
+		default:
+			j.State, j.Error = JobFailed, err.Error()
+		}
+		m.prune()
+	}()
+	return snapshot, nil
+}
+
+// close stops accepting new jobs, running jobs are not affected.
+func (m *jobManager) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+}
+
+// get returns copy of the job.
+func (m *jobManager) get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// list returns copies of all jobs from the newest one, without plans and reports.
+func (m *jobManager) list() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		j := *m.jobs[m.order[i]]
+		j.Plan, j.Report = "", nil
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+// running returns number of jobs, which are not finished yet.
+func (m *jobManager) running() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, j := range m.jobs {
+		if j.State == JobRunning {
+			count++
+		}
+	}
+	return count
+}
+
+// cancel cancels running job. False is returned when the job does not exist, or is not running anymore.
+func (m *jobManager) cancel(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || j.State != JobRunning {
+		return Job{}, false
+	}
+	j.cancel()
+	return *j, true
+}
+
+// prune removes the oldest finished jobs over the history limit. Running jobs are never removed.
+// Must be called with locked mutex.
+func (m *jobManager) prune() {
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].State != JobRunning {
+			finished++
+		}
+	}
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if finished > m.history && m.jobs[id].State != JobRunning {
+			delete(m.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
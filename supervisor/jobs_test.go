@@ -0,0 +1,196 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jobs", func() {
+	var m *jobManager
+
+	BeforeEach(func() {
+		m = newJobManager(2)
+	})
+
+	state := func(id string) func() JobState {
+		return func() JobState {
+			j, _ := m.get(id)
+			return j.State
+		}
+	}
+	// finished runs the job and waits until it ends with given state.
+	finished := func(err error, expected JobState) Job {
+		job, startErr := m.start(context.Background(), Job{Kind: jobKindMigrate}, func(
+			context.Context,
+			migrator.ProgressNotifier,
+		) (string, any, error) {
+			return "plan", "report", err
+		})
+		Expect(startErr).To(Succeed())
+		Eventually(state(job.ID)).Should(Equal(expected))
+		return job
+	}
+
+	It("Reports progress and result of the job", func() {
+		release := make(chan struct{})
+		progressed := make(chan struct{})
+		job, err := m.start(context.Background(), Job{Kind: jobKindMigrate, Batch: "schema"}, func(
+			_ context.Context,
+			progress migrator.ProgressNotifier,
+		) (string, any, error) {
+			progress(1, 3, &migrator.MigrationContext{Path: "schema/v1.0.0/01_init.cypher"})
+			close(progressed)
+			<-release
+			return "", "report", nil
+		})
+		Expect(err).To(Succeed())
+		Expect(job.ID).To(HaveLen(16))
+		Expect(job.State).To(Equal(JobRunning))
+		Expect(job.Batch).To(Equal("schema"))
+
+		<-progressed
+		running, ok := m.get(job.ID)
+		Expect(ok).To(BeTrue())
+		Expect(running.Progress).To(Equal(JobProgress{
+			StepsDone: 1, StepsTotal: 3, CurrentFile: "schema/v1.0.0/01_init.cypher"}))
+		Expect(m.running()).To(Equal(1))
+
+		close(release)
+		Eventually(state(job.ID)).Should(Equal(JobSucceeded))
+		done, _ := m.get(job.ID)
+		Expect(done.Progress).To(Equal(JobProgress{StepsDone: 3, StepsTotal: 3}))
+		Expect(done.Report).To(Equal("report"))
+		Expect(done.Error).To(BeEmpty())
+		Expect(done.FinishedAt).NotTo(BeNil())
+		Expect(m.running()).To(BeZero())
+	})
+
+	It("Fails with error of the job", func() {
+		job := finished(errors.New("exit status 1"), JobFailed)
+		failed, _ := m.get(job.ID)
+		Expect(failed.Error).To(Equal("exit status 1"))
+		Expect(failed.Report).To(Equal("report"))
+	})
+
+	It("Ends as interrupted, when migration was interrupted by shutdown", func() {
+		job := finished(fmt.Errorf("%w; exit status 1", migrator.ErrInterrupted), JobInterrupted)
+		interrupted, _ := m.get(job.ID)
+		Expect(interrupted.Error).To(Equal("execution interrupted; exit status 1"))
+	})
+
+	It("Cancels running job", func() {
+		job, err := m.start(context.Background(), Job{Kind: jobKindClean}, func(
+			ctx context.Context,
+			_ migrator.ProgressNotifier,
+		) (string, any, error) {
+			<-ctx.Done()
+			return "", nil, ctx.Err()
+		})
+		Expect(err).To(Succeed())
+
+		canceled, ok := m.cancel(job.ID)
+		Expect(ok).To(BeTrue())
+		Expect(canceled.ID).To(Equal(job.ID))
+		Eventually(state(job.ID)).Should(Equal(JobCanceled))
+		j, _ := m.get(job.ID)
+		Expect(j.Error).To(Equal("context canceled"))
+
+		_, ok = m.cancel(job.ID)
+		Expect(ok).To(BeFalse(), "finished job cannot be canceled")
+		_, ok = m.cancel("unknown")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Fails job, when parent context is canceled", func() {
+		parent, cancel := context.WithCancel(context.Background())
+		job, err := m.start(parent, Job{Kind: jobKindMigrate}, func(
+			ctx context.Context,
+			_ migrator.ProgressNotifier,
+		) (string, any, error) {
+			<-ctx.Done()
+			return "", nil, ctx.Err()
+		})
+		Expect(err).To(Succeed())
+		cancel()
+		Eventually(state(job.ID)).Should(Equal(JobFailed))
+	})
+
+	It("Keeps only limited history of finished jobs", func() {
+		release := make(chan struct{})
+		running, err := m.start(context.Background(), Job{Kind: jobKindMigrate}, func(
+			context.Context,
+			migrator.ProgressNotifier,
+		) (string, any, error) {
+			<-release
+			return "", nil, nil
+		})
+		Expect(err).To(Succeed())
+		first := finished(nil, JobSucceeded)
+		second := finished(nil, JobSucceeded)
+		third := finished(nil, JobSucceeded)
+
+		_, ok := m.get(first.ID)
+		Expect(ok).To(BeFalse(), "the oldest finished job must be pruned")
+		jobs := m.list()
+		Expect(jobs).To(HaveLen(3))
+		Expect([]string{jobs[0].ID, jobs[1].ID, jobs[2].ID}).To(Equal([]string{third.ID, second.ID, running.ID}))
+		Expect(jobs[0].Plan).To(BeEmpty())
+		Expect(jobs[0].Report).To(BeNil())
+		j, _ := m.get(third.ID)
+		Expect(j.Plan).To(Equal("plan"))
+
+		close(release)
+		Eventually(func() bool {
+			_, ok := m.get(running.ID)
+			return ok
+		}).Should(BeFalse(), "running job is pruned once it finishes as the oldest one")
+		Expect(m.list()).To(HaveLen(2))
+	})
+
+	It("Keeps no finished job with zero history", func() {
+		m = newJobManager(0)
+		job, err := m.start(context.Background(), Job{Kind: jobKindMigrate}, func(
+			context.Context,
+			migrator.ProgressNotifier,
+		) (string, any, error) {
+			return "", nil, nil
+		})
+		Expect(err).To(Succeed())
+		Eventually(m.list).Should(BeEmpty())
+		_, ok := m.get(job.ID)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Refuses new jobs after close", func() {
+		m.close()
+		_, err := m.start(context.Background(), Job{Kind: jobKindMigrate}, func(
+			context.Context,
+			migrator.ProgressNotifier,
+		) (string, any, error) {
+			Fail("closed manager must not run the job")
+			return "", nil, nil
+		})
+		Expect(err).To(MatchError(errShuttingDown))
+		Expect(m.list()).To(BeEmpty())
+	})
+})
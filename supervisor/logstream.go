@@ -0,0 +1,220 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// logSubscriberBuffer is how many lines can wait for slow subscriber. Newer lines are dropped for it then.
+	logSubscriberBuffer = 256
+	// logStreamKeepAlive is interval of comments sent to idle stream, so proxies do not close the connection.
+	logStreamKeepAlive = 15 * time.Second
+)
+
+type (
+	// logLine is single log entry sent to log stream subscribers.
+	logLine struct {
+		Time      time.Time      `json:"time"`
+		Level     string         `json:"level"`
+		Component string         `json:"component,omitempty"`
+		Message   string         `json:"message"`
+		Fields    map[string]any `json:"fields,omitempty"`
+
+		level logrus.Level
+	}
+
+	// logBroadcaster is logrus hook, which keeps last lines in ring buffer and sends new lines to subscribers.
+	logBroadcaster struct {
+		mu          sync.Mutex
+		buffer      []logLine
+		next        int
+		full        bool
+		subscribers map[chan logLine]struct{}
+	}
+
+	// logFilter selects lines by component and minimal level. Empty components match all.
+	logFilter struct {
+		components []string
+		level      logrus.Level
+	}
+)
+
+func newLogBroadcaster(size int) *logBroadcaster {
+	return &logBroadcaster{
+		buffer:      make([]logLine, size),
+		subscribers: map[chan logLine]struct{}{},
+	}
+}
+
+// Levels implements logrus.Hook.
+func (*logBroadcaster) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (b *logBroadcaster) Fire(entry *logrus.Entry) error {
+	line := logLine{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		level:   entry.Level,
+	}
+	for k, v := range entry.Data {
+		if k == componentLogKey {
+			line.Component, _ = v.(string)
+			continue
+		}
+		if line.Fields == nil {
+			line.Fields = map[string]any{}
+		}
+		// Errors are encoded to JSON as empty objects otherwise.
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		line.Fields[k] = v
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.buffer) > 0 {
+		b.buffer[b.next] = line
+		b.next = (b.next + 1) % len(b.buffer)
+		b.full = b.full || b.next == 0
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// Never block logging because of slow subscriber.
+		}
+	}
+	return nil
+}
+
+// subscribe returns buffered lines and channel with new lines. Unsubscribe must be called when done.
+func (b *logBroadcaster) subscribe() ([]logLine, <-chan logLine, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history := slices.Clone(b.buffer[:b.next])
+	if b.full {
+		history = append(slices.Clone(b.buffer[b.next:]), history...)
+	}
+	ch := make(chan logLine, logSubscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	return history, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, ch)
+	}
+}
+
+// matches checks level and component of the line. Utilities are matched also by the base name of the command.
+func (f logFilter) matches(line logLine) bool {
+	if line.level > f.level {
+		return false
+	}
+	if len(f.components) == 0 {
+		return true
+	}
+	return slices.Contains(f.components, line.Component) ||
+		slices.Contains(f.components, filepath.Base(line.Component))
+}
+
+// apiLogStreamHandler streams log lines as Server-Sent Events 'log' with JSON data.
+// Buffered lines are sent first, unless 'history=false' is set.
+// Query 'component' filters by components, can be repeated or comma separated,
+// and 'level' sets the minimal level of lines.
+func (s *httpServer) apiLogStreamHandler(c *gin.Context) {
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		s.apiAbort(c, http.StatusBadRequest, errCodeInvalidRequest, err)
+		return
+	}
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Log stream subscribed")
+
+	// Stream is open until the client disconnects, so server write timeout cannot be applied.
+	if err = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		s.httpLog.WithField("req", c.Request.URL.Path).Debugf("Cannot clear write deadline: %v", err)
+	}
+
+	history, lines, unsubscribe := s.logs.subscribe()
+	defer unsubscribe()
+	if c.Query("history") == "false" {
+		history = nil
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Disable response buffering in nginx.
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	for _, line := range history {
+		if filter.matches(line) {
+			c.SSEvent("log", line)
+		}
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(logStreamKeepAlive)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-s.closing:
+			return false
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case line := <-lines:
+			if filter.matches(line) {
+				c.SSEvent("log", line)
+			}
+			return true
+		}
+	})
+}
+
+func parseLogFilter(c *gin.Context) (logFilter, error) {
+	filter := logFilter{level: logrus.TraceLevel}
+	if level := c.Query("level"); level != "" {
+		var err error
+		if filter.level, err = logrus.ParseLevel(level); err != nil {
+			return filter, fmt.Errorf("invalid level '%s'", level)
+		}
+	}
+	for _, v := range c.QueryArray("component") {
+		for _, component := range strings.Split(v, ",") {
+			if component = strings.TrimSpace(component); component != "" {
+				filter.components = append(filter.components, component)
+			}
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+)
+
+const metricsNamespace = "graph_tool"
+
+var allStates = []Neo4jState{Stopped, Failed, Starting, Updating, Stopping, Running, Restarting, Maintenance}
+
+type (
+	// metrics holds all Prometheus metrics of the supervisor in its own registry.
+	metrics struct {
+		registry *prometheus.Registry
+
+		restarts          prometheus.Counter
+		crashes           prometheus.Counter
+		startupSeconds    prometheus.Gauge
+		migrationRuns     *prometheus.CounterVec
+		migrationFailures *prometheus.CounterVec
+		filesApplied      *prometheus.CounterVec
+		stepSeconds       *prometheus.HistogramVec
+		appliedVersion    *prometheus.GaugeVec
+	}
+
+	// stateCollector reports current Neo4j state during scrape, so all state changes do not need to be tracked.
+	stateCollector struct {
+		w    *Neo4jWrapper
+		desc *prometheus.Desc
+	}
+)
+
+func newMetrics(w *Neo4jWrapper) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "neo4j_restarts_total",
+			Help:      "Number of Neo4j restarts, both requested and automatic after crash.",
+		}),
+		crashes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "neo4j_crashes_total",
+			Help:      "Number of unexpected Neo4j process exits.",
+		}),
+		startupSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "neo4j_startup_seconds",
+			Help:      "Duration of the last Neo4j start until Bolt was reachable.",
+		}),
+		migrationRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "migration_runs_total",
+			Help:      "Number of migration runs, without dry runs.",
+		}, []string{"batch"}),
+		migrationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "migration_failures_total",
+			Help:      "Number of failed migration runs. Canceled runs are not counted.",
+		}, []string{"batch"}),
+		filesApplied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "migration_files_applied_total",
+			Help:      "Number of successfully applied migration files.",
+		}, []string{"folder"}),
+		stepSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "migration_step_duration_seconds",
+			Help:      "Duration of steps of single migration file, every retry attempt is observed separately.",
+			Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+		}, []string{"folder"}),
+		appliedVersion: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "applied_version_info",
+			Help:      "Highest applied version of the folder in the database, value is always 1.",
+		}, []string{"folder", "version"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		&stateCollector{w: w, desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "neo4j_state"),
+			"Current Neo4j state, value is 1 for the current state and 0 for others.",
+			[]string{"state"}, nil,
+		)},
+		m.restarts,
+		m.crashes,
+		m.startupSeconds,
+		m.migrationRuns,
+		m.migrationFailures,
+		m.filesApplied,
+		m.stepSeconds,
+		m.appliedVersion,
+	)
+	return m
+}
+
+// observeReport records executed steps of single migration file and applied files.
+func (m *metrics) observeReport(report *migrator.ExecutionReport) {
+	if report == nil {
+		return
+	}
+	for _, step := range report.Steps {
+		if step.Migration != nil {
+			m.stepSeconds.WithLabelValues(step.Migration.FolderName).Observe(step.Duration.Seconds())
+		}
+	}
+	for _, mc := range report.Applied {
+		m.filesApplied.WithLabelValues(mc.FolderName).Inc()
+	}
+}
+
+// setAppliedVersions replaces applied versions of all folders. Folders missing in the model are removed.
+func (m *metrics) setAppliedVersions(model migrator.DatabaseModel) {
+	m.appliedVersion.Reset()
+	for folder, versions := range model {
+		var highest *migrator.DatabaseGraphVersion
+		for i := range versions {
+			if highest == nil || highest.Version.LessThan(versions[i].Version) {
+				highest = &versions[i]
+			}
+		}
+		if highest != nil {
+			m.appliedVersion.WithLabelValues(folder, highest.Version.String()).Set(1)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	current, err := c.w.State()
+	if err != nil {
+		return
+	}
+	for _, state := range allStates {
+		value := 0.0
+		if state == current {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, string(state))
+	}
+}
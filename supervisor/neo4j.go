@@ -16,14 +16,18 @@ package supervisor
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/semaphore"
 
 	"github.com/indykite/neo4j-graph-tool-core/config"
@@ -33,13 +37,12 @@ import (
 type Neo4jState string
 
 const (
-	boltAddr              = "bolt://127.0.0.1:7687"
-	boltCheckSec          = 2
-	boltCheckQuitAfterSec = 5 * 60
-	initialDataDir        = "/initial-data/"
-	componentLogKey       = "system"
-	dockerEntryPointPath  = "/startup/docker-entrypoint.sh"
-	graphToolPath         = "/app/graph-tool"
+	componentLogKey      = "system"
+	tracerName           = "github.com/indykite/neo4j-graph-tool-core/supervisor"
+	dockerEntryPointPath = "/startup/docker-entrypoint.sh"
+	graphToolPath        = "/app/graph-tool"
+	// slowestMigrationsCount is how many migration files are printed in summary after import.
+	slowestMigrationsCount = 5
 
 	Stopped  Neo4jState = "Stopped"
 	Failed   Neo4jState = "Failed"
@@ -47,27 +50,62 @@ const (
 	Updating Neo4jState = "Updating Data"
 	Stopping Neo4jState = "Stopping"
 	Running  Neo4jState = "Running"
+	// Restarting is set after crash, while waiting for backoff before the next start.
+	Restarting Neo4jState = "Restarting"
+	// Maintenance is set while stopped Neo4j is being backed up or restored.
+	Maintenance Neo4jState = "Maintenance"
 )
 
 var (
-	processArgs       = []string{dockerEntryPointPath, "neo4j"}
 	cancelWaitingChan chan os.Signal
 	// Semaphore supports TryAcquire which can checks locks only, and not block execution.
 	serviceSem = semaphore.NewWeighted(1)
 	spinUpMux  = &sync.Mutex{}
 	utilsMux   = &sync.Mutex{}
+	reportMux  = &sync.Mutex{}
 )
 
+// StateError is returned, when requested action cannot be done in the current service state.
+type StateError struct {
+	msg string
+}
+
+func (e *StateError) Error() string {
+	return e.msg
+}
+
+func stateErrorf(format string, a ...any) error {
+	return &StateError{msg: fmt.Sprintf(format, a...)}
+}
+
 // Neo4jWrapper wraps command and helper functions to operate with Neo4j server together with utilities.
 type Neo4jWrapper struct {
 	driver  neo4j.Driver
 	context context.Context //nolint:containedctx // Context here is expected and required, it is root context.
 	cfg     *config.Config
 
-	serviceCmd   *TSCmd
+	process      ProcessBackend
+	started      bool
+	serviceDone  chan struct{}
 	log          *logrus.Entry
 	utilsCmd     map[string]*TSCmd
 	serviceState Neo4jState
+	startedAt    time.Time
+	lastReport   *migrator.ExecutionReport
+	metrics      *metrics
+	webhooks     *webhookNotifier
+
+	// Automatic restarts after crash, guarded by serviceSem as the service state.
+	restarts      int
+	restartTimer  *time.Timer
+	nextRestartAt time.Time
+
+	readinessHooks []ReadinessHook
+
+	// interrupt is closed on shutdown, running migrations stop after the current step.
+	// Migrations counts them together with running backups and restores.
+	interrupt  chan struct{}
+	migrations sync.WaitGroup
 }
 
 // NewNeo4jWrapper creates wrapper for handling Neo4j and utilities.
@@ -78,13 +116,29 @@ func NewNeo4jWrapper(ctx context.Context, cfg *config.Config, log *logrus.Entry)
 		serviceState: Stopped,
 		utilsCmd:     map[string]*TSCmd{},
 		log:          log,
+		interrupt:    make(chan struct{}),
 	}
+	w.metrics = newMetrics(w)
+	var hooks []*config.Webhook
+	var processCfg *config.Process
+	if cfg.Supervisor != nil {
+		hooks = cfg.Supervisor.Webhooks
+		processCfg = cfg.Supervisor.Process
+	}
+	w.process = NewProcessBackend(processCfg)
+	w.webhooks = newWebhookNotifier(hooks, log.WithField(componentLogKey, "webhooks"))
 	var err error
-	w.driver, err = neo4j.NewDriver(boltAddr, neo4j.BasicAuth(w.getNeo4jBasicAuth()))
+	w.driver, err = migrator.NewDriver(cfg.Connection)
 
 	return w, err
 }
 
+// WithProcessBackend replaces backend selected in config. It must be called before Neo4j is started.
+func (w *Neo4jWrapper) WithProcessBackend(backend ProcessBackend) *Neo4jWrapper {
+	w.process = backend
+	return w
+}
+
 // State returns the current service state.
 func (w *Neo4jWrapper) State() (Neo4jState, error) {
 	if err := serviceSem.Acquire(w.context, 1); err != nil {
@@ -94,13 +148,16 @@ func (w *Neo4jWrapper) State() (Neo4jState, error) {
 	return w.serviceState, nil
 }
 
-// setState sets the current service state.
-func (w *Neo4jWrapper) setState(state Neo4jState) error {
+// finishUpdate sets the state after migration. State is kept, when Neo4j crashed during the migration.
+func (w *Neo4jWrapper) finishUpdate(state Neo4jState) error {
+	defer w.migrations.Done()
 	if err := serviceSem.Acquire(w.context, 1); err != nil {
 		return err
 	}
 	defer serviceSem.Release(1)
-	w.serviceState = state
+	if w.serviceState == Updating {
+		w.serviceState = state
+	}
 	return nil
 }
 
@@ -110,6 +167,11 @@ func (w *Neo4jWrapper) AllStates() map[string]any {
 	m := map[string]any{"neo4j": state}
 	if err != nil {
 		m["neo4_state_err"] = err
+	} else if restarts, at := w.restartInfo(); restarts > 0 {
+		m["neo4j_restarts"] = restarts
+		if state == Restarting {
+			m["neo4j_restart_at"] = at
+		}
 	}
 	utilsMux.Lock()
 	defer utilsMux.Unlock()
@@ -124,21 +186,36 @@ func (w *Neo4jWrapper) AllStates() map[string]any {
 func (w *Neo4jWrapper) Start() error {
 	// Ensure there are no multiple operations running at the same time
 	if !serviceSem.TryAcquire(1) {
-		return fmt.Errorf("cannot Start service, currently is '%s'", w.serviceState)
+		return stateErrorf("cannot Start service, currently is '%s'", w.serviceState)
 	}
 	defer serviceSem.Release(1)
-	if w.serviceCmd != nil {
-		return fmt.Errorf("service is in '%s' state already, cannot be started again", w.serviceState)
+	// Requested start gives crashing Neo4j new chance, even when max restarts were reached.
+	w.restarts = 0
+	return w.start()
+}
+
+// start starts neo4j process and its watcher. Must be called with acquired serviceSem.
+func (w *Neo4jWrapper) start() error {
+	if w.started || w.serviceState == Maintenance {
+		return stateErrorf("service is in '%s' state already, cannot be started again", w.serviceState)
+	}
+	if w.restartTimer != nil {
+		// Started manually during backoff, scheduled restart is not needed anymore.
+		w.restartTimer.Stop()
+		w.restartTimer = nil
 	}
 	w.log.Debug("Starting neo4j process")
 	w.serviceState = Starting
-	var err error
-	w.serviceCmd, err = StartCmd(w.log.WithField(componentLogKey, "neo4j"), nil, processArgs...)
-	if err != nil {
+	w.startedAt = time.Now()
+	if err := w.process.Start(w.log.WithField(componentLogKey, "neo4j")); err != nil {
 		w.serviceState = Failed
+		w.emitFailure("Neo4j failed to start", err, nil)
 		return fmt.Errorf("cannot start neo4j - %v", err.Error())
 	}
 	w.log.Trace("Process neo4j started")
+	w.started = true
+	w.serviceDone = make(chan struct{})
+	go w.watch(w.serviceDone)
 
 	// It will set Started state, but no need to wait for it to finish
 	go func() { _ = w.WaitForNeo4j() }()
@@ -155,47 +232,50 @@ func (w *Neo4jWrapper) Stop() error {
 		return err
 	}
 	defer serviceSem.Release(1)
+	return w.stop(os.Interrupt)
+}
 
+// stop sends the signal to neo4j process, or cancels scheduled restart. Must be called with acquired serviceSem.
+func (w *Neo4jWrapper) stop(sig os.Signal) error {
 	if w.serviceState == Stopping {
 		w.log.Trace("Stop request ignored - service is stopping")
 		return nil
 	}
-	if w.serviceCmd == nil {
-		return fmt.Errorf("service cannot be stopped, it is '%s'", w.serviceState)
+	if w.serviceState == Restarting {
+		w.restartTimer.Stop()
+		w.restartTimer = nil
+		w.serviceState = Stopped
+		w.log.Debug("Scheduled restart canceled")
+		return nil
 	}
-	if cancelWaitingChan != nil {
-		// Prevent from blocking if writing multiple times to same channel. Should never happen, but...
-		select {
-		case cancelWaitingChan <- os.Interrupt:
-			w.log.Trace("Interrupting signal was sent to Bolt opening checks")
-		default:
-			w.log.Warn("Interrupting signal was sent to Bolt opening checks - channel is full")
-		}
+	if !w.started {
+		return stateErrorf("service cannot be stopped, it is '%s'", w.serviceState)
 	}
-	w.log.Trace("Interrupting signal sent")
-	err := w.serviceCmd.Process.Signal(os.Interrupt)
+	w.cancelBoltChecks()
+	w.log.WithField("signal", sig).Trace("Stopping signal sent")
+	err := w.process.Signal(sig)
 	if err != nil {
 		return err
 	}
+	// Watcher started with the process cleans up the state after exit.
 	w.serviceState = Stopping
-
-	// Wait only for setting correct state, but not block current thread
-	go func() {
-		w.log.Trace("Waiting for neo4j to exit to cleanup state")
-		_ = w.serviceCmd.WaitTS()
-		if err := serviceSem.Acquire(w.context, 1); err == nil {
-			w.serviceCmd = nil
-			w.serviceState = Stopped
-			serviceSem.Release(1)
-			w.log.Trace("Cleaned up after neo4j exited")
-		} else {
-			w.log.Errorf("Cannot clean up after neo4j exited: %v", err)
-		}
-	}()
-
 	return nil
 }
 
+// cancelBoltChecks stops waiting for Bolt in WaitForNeo4j. Must be called with acquired serviceSem.
+func (w *Neo4jWrapper) cancelBoltChecks() {
+	if cancelWaitingChan == nil {
+		return
+	}
+	// Prevent from blocking if writing multiple times to same channel. Should never happen, but...
+	select {
+	case cancelWaitingChan <- os.Interrupt:
+		w.log.Trace("Interrupting signal was sent to Bolt opening checks")
+	default:
+		w.log.Warn("Interrupting signal was sent to Bolt opening checks - channel is full")
+	}
+}
+
 // Restart call Stop, Wait and Start.
 func (w *Neo4jWrapper) Restart() error {
 	stopErr := w.Stop()
@@ -210,7 +290,9 @@ func (w *Neo4jWrapper) Restart() error {
 		err = w.Start()
 		if err != nil {
 			w.log.Errorf("Failed to restart service: %v", err)
+			return
 		}
+		w.metrics.restarts.Inc()
 	}()
 
 	return stopErr
@@ -218,6 +300,14 @@ func (w *Neo4jWrapper) Restart() error {
 
 // StopAll sends Interrupt signal for all processes and then stops main Neo4j process, but does not wait for exit.
 func (w *Neo4jWrapper) StopAll() error {
+	if err := w.interruptUtilities(); err != nil {
+		return err
+	}
+	return w.Stop()
+}
+
+// interruptUtilities sends Interrupt signal to all running utilities.
+func (w *Neo4jWrapper) interruptUtilities() error {
 	utilsMux.Lock()
 	defer utilsMux.Unlock()
 	for un, v := range w.utilsCmd {
@@ -229,22 +319,22 @@ func (w *Neo4jWrapper) StopAll() error {
 			}
 		}
 	}
-
-	return w.Stop()
+	return nil
 }
 
 // Wait waits until main process is exited.
 // To wait for utilities use WaitAll.
 func (w *Neo4jWrapper) Wait() error {
-	// Create copy of TSCmd to avoid locking all other methods when waiting
+	// Copy the channel to avoid locking all other methods when waiting
 	if err := serviceSem.Acquire(w.context, 1); err != nil {
 		return err
 	}
-	serviceCmd := w.serviceCmd
+	// Wait for the watcher, so the state is cleaned up already when returning.
+	done := w.serviceDone
 	serviceSem.Release(1)
 
-	if serviceCmd != nil {
-		_ = serviceCmd.WaitTS()
+	if done != nil {
+		<-done
 	}
 	w.log.Trace("Neo4j Exited")
 	return nil
@@ -272,7 +362,7 @@ func (w *Neo4jWrapper) WaitAll() error {
 }
 
 // WaitForNeo4j blocks execution until Neo4j is ready, or returns error if service is not starting.
-// Also returns error after 5 minutes of trying to wait.
+// Also returns error, when Neo4j is not ready within configured readiness timeout.
 func (w *Neo4jWrapper) WaitForNeo4j() (err error) {
 	// Block the function until Neo4j is ready.
 	// Only first call to this method will start net.Dial, others are just waiting
@@ -294,25 +384,31 @@ func (w *Neo4jWrapper) WaitForNeo4j() (err error) {
 		}
 		cancelWaitingChan = make(chan os.Signal, 1)
 		serviceSem.Release(1)
-		ticker := time.NewTicker(boltCheckSec * time.Second)
+		readiness := w.readinessConfig()
+		ticker := time.NewTicker(readiness.Interval)
+		defer ticker.Stop()
+		// Nil channel blocks forever, so zero timeout waits until Neo4j is ready or stopped.
+		var deadline <-chan time.Time
+		if readiness.Timeout > 0 {
+			deadline = time.After(readiness.Timeout)
+		}
 		connected := false
 		w.log.Trace("Starting Bolt checking loop")
 	outerLoop:
-		for i := 0; ; i++ {
+		for {
 			select {
 			case <-cancelWaitingChan:
-				ticker.Stop()
 				cancelled = true
 				break outerLoop
+			case <-deadline:
+				break outerLoop
 			case <-ticker.C:
-				if i > (boltCheckQuitAfterSec / boltCheckSec) {
-					break outerLoop
-				}
-				if err := w.driver.VerifyConnectivity(w.context); err == nil {
+				checkErr := w.checkReady(w.context)
+				if checkErr == nil {
 					connected = true
 					break outerLoop
 				}
-				w.log.Tracef("Bolt port is not ready yet, waiting %d seconds", boltCheckSec)
+				w.log.Tracef("Neo4j is not ready yet, waiting %s: %v", readiness.Interval, checkErr)
 			}
 		}
 		if err := serviceSem.Acquire(w.context, 1); err != nil {
@@ -325,9 +421,13 @@ func (w *Neo4jWrapper) WaitForNeo4j() (err error) {
 		if connected {
 			w.log.Debug("Bolt port is ready, DB connected")
 			w.serviceState = Running
+			startup := time.Since(w.startedAt)
+			w.metrics.startupSeconds.Set(startup.Seconds())
+			w.emitReady(startup)
 		} else if !cancelled {
-			w.log.Warnf("Bolt port is not ready after %d seconds, not checking anymore", boltCheckQuitAfterSec)
+			w.log.Warnf("Neo4j is not ready after %s, not checking anymore", readiness.Timeout)
 			w.serviceState = Failed
+			w.emitFailure("Neo4j failed to start", fmt.Errorf("neo4j is not ready after %s", readiness.Timeout), nil)
 		}
 	}
 
@@ -335,7 +435,23 @@ func (w *Neo4jWrapper) WaitForNeo4j() (err error) {
 	if w.serviceState == Running || cancelled {
 		return nil
 	}
-	return fmt.Errorf("cannot wait for Neo4j, service is '%s'", w.serviceState)
+	return stateErrorf("cannot wait for Neo4j, service is '%s'", w.serviceState)
+}
+
+// MigrateOptions specifies migration run, see Neo4jWrapper.Migrate.
+type MigrateOptions struct {
+	Target *migrator.TargetVersion
+	Batch  migrator.Batch
+	// DryRun only plans the migration and prints the plan.
+	DryRun bool
+	// Clean wipes out the database and runs all migrations from the beginning.
+	Clean bool
+	// Confirm must match config.Planner.ConfirmToken to clean or roll back protected batch.
+	Confirm string
+	// Retry overrides config.Planner.Retry for this run, if set.
+	Retry *config.RetryPolicy
+	// Progress is called before every executed step, if set.
+	Progress migrator.ProgressNotifier
 }
 
 // RefreshData imports all data from schema import folder.
@@ -344,11 +460,103 @@ func (w *Neo4jWrapper) RefreshData(
 	dryRun, clean bool,
 	batchName migrator.Batch,
 ) error {
-	err := w.update(targetVersion, dryRun, clean, batchName)
+	_, err := w.Migrate(MigrateOptions{Target: targetVersion, Batch: batchName, DryRun: dryRun, Clean: clean})
+	return err
+}
+
+// Migrate plans and executes migrations according to given options. Planned steps are returned,
+// so caller can see what was executed, or what would be executed in case of dry run.
+func (w *Neo4jWrapper) Migrate(opts MigrateOptions) (*migrator.ExecutionSteps, error) {
+	return w.MigrateContext(w.context, opts)
+}
+
+// MigrateContext is like Migrate, but the migration can be canceled with given context.
+// Canceled migration does not mark service as failed. Clean migration is preceded by automatic backup,
+// when enabled in config.Backup. Clean migration or rollback of protected batch is refused without
+// confirmation token, see migrator.ErrProtected.
+func (w *Neo4jWrapper) MigrateContext(ctx context.Context, opts MigrateOptions) (*migrator.ExecutionSteps, error) {
+	if opts.Clean && !opts.DryRun {
+		// Refuse before backup, so nothing is touched. Rollbacks are checked after planning.
+		if err := w.checkCleanProtection(opts.Batch, opts.Confirm); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Clean && !opts.DryRun && w.backupConfig().BeforeClean {
+		// Backup requires restart, so it is done only when migration can run. Otherwise update reports the state.
+		if state, _ := w.State(); state == Running {
+			if err := w.backupBeforeClean(ctx); err != nil {
+				return nil, fmt.Errorf("backup before clean failed: %w", err)
+			}
+		}
+	}
+	steps, err := w.update(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("importing data failed: %w", err)
+		return steps, fmt.Errorf("importing data failed: %w", err)
 	}
-	return nil
+	return steps, nil
+}
+
+// UpdateTenants runs migrations in all tenant databases from config.Tenants.
+// Report is returned even if some tenants failed, so caller can see which ones.
+func (w *Neo4jWrapper) UpdateTenants(
+	targetVersion *migrator.TargetVersion,
+	batchName migrator.Batch,
+) (*migrator.TenantsReport, error) {
+	return w.UpdateTenantsContext(w.context, targetVersion, batchName)
+}
+
+// UpdateTenantsContext is like UpdateTenants, but the migration can be canceled with given context.
+func (w *Neo4jWrapper) UpdateTenantsContext(
+	ctx context.Context,
+	targetVersion *migrator.TargetVersion,
+	batchName migrator.Batch,
+) (*migrator.TenantsReport, error) {
+	return w.MigrateTenantsContext(ctx, MigrateOptions{Target: targetVersion, Batch: batchName})
+}
+
+// MigrateTenantsContext is like UpdateTenantsContext, but takes options. Only Target, Batch and Confirm are used.
+func (w *Neo4jWrapper) MigrateTenantsContext(
+	ctx context.Context,
+	opts MigrateOptions,
+) (report *migrator.TenantsReport, err error) {
+	targetVersion, batchName := opts.Target, opts.Batch
+	if err = w.setUpdatingStateWhenRunning(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Failed tenants do not mean Neo4j itself is broken, so state goes back to running.
+		if stateErr := w.finishUpdate(Running); err == nil {
+			err = stateErr
+		}
+	}()
+
+	w.log.WithFields(logrus.Fields{
+		"batch":  batchName,
+		"target": targetVersion,
+	}).Debug("Updating tenants")
+
+	runner, err := migrator.NewRunnerWithDriver(w.cfg, w.driver, w.runTenantUtility)
+	if err != nil {
+		return nil, err
+	}
+	report, err = runner.WithInterrupt(w.interrupt).RunTenants(ctx, migrator.RunOptions{
+		ImportDir: w.getImportDir(),
+		Target:    targetVersion,
+		Batch:     batchName,
+		Confirm:   opts.Confirm,
+	})
+	if report != nil {
+		w.log.WithFields(logrus.Fields{
+			"succeeded": len(report.Succeeded),
+			"failed":    len(report.Failed),
+			"current":   len(report.Current),
+			"duration":  report.Duration,
+		}).Info("Tenants update finished")
+	}
+	if err != nil {
+		return report, fmt.Errorf("updating tenants failed: %w", err)
+	}
+	return report, nil
 }
 
 func (w *Neo4jWrapper) setUpdatingStateWhenRunning() error {
@@ -358,131 +566,250 @@ func (w *Neo4jWrapper) setUpdatingStateWhenRunning() error {
 	}
 	defer serviceSem.Release(1)
 
+	if w.isShuttingDown() {
+		return errShuttingDown
+	}
 	if w.serviceState != Running {
-		return fmt.Errorf("cannot run import when service is '%s', must be running", w.serviceState)
+		return stateErrorf("cannot run import when service is '%s', must be running", w.serviceState)
 	}
 	w.serviceState = Updating
+	// Shutdown closes interrupt with acquired serviceSem, so no migration is added after it waits.
+	w.migrations.Add(1)
 	return nil
 }
 
 // update set Updating state in the beginning, but not at the end.
 func (w *Neo4jWrapper) update(
-	targetVersion *migrator.TargetVersion,
-	dryRun, clean bool,
-	batchName migrator.Batch,
-) (err error) {
+	ctx context.Context,
+	opts MigrateOptions,
+) (execSteps *migrator.ExecutionSteps, err error) {
 	// This check must be done before setting up the defer below.
 	err = w.setUpdatingStateWhenRunning()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "Neo4jWrapper.Migrate", trace.WithAttributes(
+		attribute.String("graph_tool.batch", string(opts.Batch)),
+		attribute.String("graph_tool.target_version", opts.Target.String()),
+		attribute.Bool("graph_tool.clean", opts.Clean),
+		attribute.Bool("graph_tool.dry_run", opts.DryRun),
+	))
+
+	// summary is set only when migration started, so webhooks get only finished runs they were notified about.
+	var summary *migrationSummary
+	startedAt := time.Now()
+
 	// This defer will happen after return and thus have access to returned value.
 	// So program can act accordingly and even change it.
 	defer func() {
 		if err != nil {
-			_ = w.setState(Failed)
-		} else {
-			err = w.setState(Running)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		if summary != nil {
+			duration := time.Since(startedAt).Round(time.Millisecond)
+			summary.Duration = duration.String()
+			if err != nil {
+				summary.Error = err.Error()
+				w.webhooks.notify(config.EventMigrationFailed,
+					fmt.Sprintf("Migration of batch '%s' failed: %v", opts.Batch, err), summary)
+			} else {
+				w.webhooks.notify(config.EventMigrationFinished,
+					fmt.Sprintf("Migration of batch '%s' finished in %s", opts.Batch, duration), summary)
+			}
+		}
+
+		canceled := ctx.Err() != nil && w.context.Err() == nil
+		// Refused migration did not start, so it is not counted at all.
+		refused := errors.Is(err, migrator.ErrProtected)
+		interrupted := errors.Is(err, migrator.ErrInterrupted)
+		if !opts.DryRun && !refused {
+			w.metrics.migrationRuns.WithLabelValues(string(opts.Batch)).Inc()
+			if err != nil && !canceled && !interrupted {
+				w.metrics.migrationFailures.WithLabelValues(string(opts.Batch)).Inc()
+			}
+		}
+		switch {
+		case err == nil:
+			err = w.finishUpdate(Running)
+		case canceled, refused, interrupted:
+			// Only migration was canceled, refused or interrupted, Neo4j itself keeps running.
+			_ = w.finishUpdate(Running)
+		default:
+			_ = w.finishUpdate(Failed)
 		}
 	}()
 
 	w.log.WithFields(logrus.Fields{
-		"clean":  clean,
-		"batch":  batchName,
-		"dryRun": dryRun,
-		"target": targetVersion,
+		"clean":  opts.Clean,
+		"batch":  opts.Batch,
+		"dryRun": opts.DryRun,
+		"target": opts.Target,
 	}).Debug("Refreshing data")
 
-	// We already validated config before
-	p, _ := migrator.NewPlanner(w.cfg)
+	p, err := migrator.NewPlanner(w.configWithRetry(opts.Retry))
+	if err != nil {
+		return nil, err
+	}
+
+	// Dry run should not change anything, so missing database fails when fetching version.
+	if !opts.DryRun {
+		if err = w.createDatabases(ctx, p, opts.Batch); err != nil {
+			return nil, err
+		}
+	}
 
 	var dbModel migrator.DatabaseModel
-	if !clean {
+	if !opts.Clean {
 		w.log.Trace("Connecting to DB to fetch current version")
 
-		session := w.ReadOnlySession(w.context)
-		defer func() { _ = session.Close(w.context) }()
-		dbModel, err = p.Version(w.context, session)
+		var dbModels migrator.DatabaseModels
+		dbModels, err = p.Versions(ctx,
+			migrator.NewSessionFactory(w.driver, w.cfg.Connection, neo4j.AccessModeRead))
 		if err != nil {
-			return err
+			return nil, err
 		}
+		dbModel = dbModels.Merge()
+		w.metrics.setAppliedVersions(dbModel)
 		w.log.WithField("db_model", dbModel).Trace("DB version fetched")
 	}
 
 	scanner, err := p.NewScanner(w.getImportDir())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	w.log.WithField("folder", w.getImportDir()).Trace("Scanning folders")
-	lf, err := scanner.ScanFolders()
+	lf, err := scanner.ScanFoldersContext(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	execSteps := new(migrator.ExecutionSteps)
-	if clean {
-		if err = w.drop(execSteps); err != nil {
-			return err
+	execSteps = new(migrator.ExecutionSteps)
+	if opts.Clean {
+		if err = w.drop(ctx, p, execSteps, opts.Batch); err != nil {
+			return nil, err
 		}
 	}
 
-	err = p.Plan(lf, dbModel, targetVersion, batchName, p.CreateBuilder(execSteps, true))
+	err = p.PlanContext(ctx, lf, dbModel, opts.Target, opts.Batch, p.CreateBuilder(execSteps, true))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	switch {
 	case execSteps.IsEmpty():
 		w.log.Debug("Nothing to change")
-		return nil
-	case dryRun:
+		return execSteps, nil
+	case opts.DryRun:
 		fmt.Print(execSteps.String())
-		return nil
+		return execSteps, nil
+	}
+	if err = p.CheckProtection(opts.Batch, opts.Clean, *execSteps, opts.Confirm); err != nil {
+		return nil, err
+	}
+
+	summary = newMigrationSummary(opts, *execSteps)
+	w.webhooks.notify(config.EventMigrationStarted, fmt.Sprintf("Migration of batch '%s' started, %d files to apply",
+		opts.Batch, len(summary.Files)), summary)
+
+	// Connection variables are used by cypher-shell, and custom commands should accept them in the same way.
+	// Batch is specific to current run.
+	env := append(migrator.ConnectionEnv(w.cfg.Connection), migrator.EnvMigrationBatch+"="+string(opts.Batch))
+	executor := p.NewExecutor(w.runUtility, env...).
+		WithAppliedChecker(p.NewAppliedChecker(w.driver, w.cfg.Connection)).
+		WithRetryNotifier(func(step *migrator.StepReport, delay time.Duration) {
+			w.log.WithFields(logrus.Fields{
+				"attempt": step.Attempt,
+				"delay":   delay,
+			}).Warnf("Step failed with transient error, will retry: %s", step.Error)
+		}).
+		WithProgressNotifier(opts.Progress).
+		WithInterrupt(w.interrupt)
+	report, err := executor.Execute(ctx, *execSteps)
+	w.storeReport(report)
+	w.metrics.observeReport(report)
+	// Failed and interrupted runs might apply some files too, and clean run removes versions of other folders.
+	w.refreshVersionMetrics(ctx, p)
+	if report.Interrupted {
+		w.log.WithField("steps", len(report.Steps)).Warn("Import interrupted by shutdown, remaining steps were skipped")
+		return execSteps, err
+	}
+	if err != nil {
+		w.log.Warnf("Failed to import file: %v", err)
+		return execSteps, err
 	}
+	w.log.WithField("duration", report.Duration).Info("Import finished")
+	w.logSlowest(report)
 
-	user, pass, _ := w.getNeo4jBasicAuth()
-	// Set environment variables, because the values might come from config.
-	// Those variables are added automatically to starting utility. And cypher-shell accept it.
-	// Custom commands should accept it as well in the same way as cypher-shell does.
-	_ = os.Setenv("NEO4J_USERNAME", user)
-	_ = os.Setenv("NEO4J_PASSWORD", pass)
-	if w.cfg.Supervisor.Neo4jDatabase != "" {
-		_ = os.Setenv("NEO4J_DATABASE", w.cfg.Supervisor.Neo4jDatabase)
-	}
-
-	for _, step := range *execSteps {
-		if step.IsCypher() {
-			err = w.startUtility(true, step.Cypher(),
-				"cypher-shell", "--fail-fast", "--format", w.cfg.Planner.CypherShellFormat)
-		} else {
-			toExec := step.Command()
-			if toExec[0] == "exit" {
-				continue
-			}
+	return execSteps, nil
+}
 
-			err = w.startUtility(true, nil, toExec...)
-		}
-		if err != nil {
-			w.log.Warnf("Failed to import file: %v", err)
-			break
-		}
+// logSlowest prints summary of migration files, which took the most time.
+func (w *Neo4jWrapper) logSlowest(report *migrator.ExecutionReport) {
+	for _, f := range report.Slowest(slowestMigrationsCount) {
+		w.log.WithFields(logrus.Fields{
+			"folder":   f.Migration.FolderName,
+			"version":  f.Migration.Version.String(),
+			"revision": f.Migration.Revision,
+			"duration": f.Duration,
+		}).Info("Slow migration file")
 	}
-	w.log.Info("Import finished")
+}
 
-	return nil
+// LastReport returns report of the last executed migration, or nil if nothing was executed yet.
+func (w *Neo4jWrapper) LastReport() *migrator.ExecutionReport {
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	return w.lastReport
+}
+
+func (w *Neo4jWrapper) storeReport(report *migrator.ExecutionReport) {
+	reportMux.Lock()
+	w.lastReport = report
+	reportMux.Unlock()
+
+	if w.cfg.Planner.ReportFile == "" {
+		return
+	}
+	reportPath := w.getReportPath()
+	if err := report.WriteFile(reportPath); err != nil {
+		w.log.WithError(err).Warn("Cannot write execution report")
+		return
+	}
+	w.log.WithField("file", reportPath).Debug("Execution report written")
+}
+
+// runUtility implements migrator.ProcessRunner, so migrations are tracked as other utilities.
+func (w *Neo4jWrapper) runUtility(ctx context.Context, proc *migrator.Process) error {
+	return w.startUtility(ctx, true, proc)
+}
+
+// runTenantUtility implements migrator.ProcessRunner for tenant migrations. Tenants are migrated in parallel,
+// so processes cannot be tracked as single utility. Output is logged the same way, with the tenant database.
+func (w *Neo4jWrapper) runTenantUtility(ctx context.Context, proc *migrator.Process) error {
+	ul := w.utilsLog(proc.Args[0]).WithField("database", envValue(proc.Env, migrator.EnvNeo4jDatabase))
+	cmd, err := startCmd(ctx, ul, proc)
+	if err != nil {
+		return err
+	}
+	return cmd.WaitTS()
 }
 
-func (w *Neo4jWrapper) startUtility(wait bool, stdin io.Reader, args ...string) error {
-	utilName := args[0]
+func (w *Neo4jWrapper) startUtility(ctx context.Context, wait bool, proc *migrator.Process) error {
+	utilName := proc.Args[0]
 	utilsMux.Lock()
 	ul := w.utilsLog(utilName)
 	if _, found := w.utilsCmd[utilName]; found {
+		utilsMux.Unlock()
 		ul.Debug("Utility cannot be started more than once")
 		return fmt.Errorf("utility '%s' is already running", utilName)
 	}
 	ul.Trace("Starting utility")
-	cmd, err := StartCmd(ul, stdin, args...)
+	cmd, err := startCmd(ctx, ul, proc)
 	if err != nil {
+		utilsMux.Unlock()
 		return err
 	}
 	w.utilsCmd[utilName] = cmd
@@ -491,7 +818,7 @@ func (w *Neo4jWrapper) startUtility(wait bool, stdin io.Reader, args ...string)
 
 	waitAndClean := func() error {
 		ul.Trace("Starting to clean up")
-		err = cmd.WaitTS()
+		err := cmd.WaitTS()
 		utilsMux.Lock()
 		defer utilsMux.Unlock()
 		delete(w.utilsCmd, utilName)
@@ -0,0 +1,102 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+)
+
+// ReadinessHook is called with nil error, when started Neo4j becomes ready, or with error, when it failed
+// to start, was not ready in time, or exited unexpectedly.
+type ReadinessHook func(err error)
+
+var readinessHooksMux = &sync.Mutex{}
+
+// OnReadiness registers the hook. Hooks are called in own goroutines, so they can call the wrapper.
+func (w *Neo4jWrapper) OnReadiness(hook ReadinessHook) *Neo4jWrapper {
+	readinessHooksMux.Lock()
+	defer readinessHooksMux.Unlock()
+	w.readinessHooks = append(w.readinessHooks, hook)
+	return w
+}
+
+// readinessConfig returns configured readiness. Without supervisor config, defaults are used.
+func (w *Neo4jWrapper) readinessConfig() *config.Readiness {
+	if w.cfg.Supervisor == nil || w.cfg.Supervisor.Readiness == nil {
+		return &config.Readiness{Interval: config.DefaultReadinessInterval, Timeout: config.DefaultReadinessTimeout}
+	}
+	return w.cfg.Supervisor.Readiness
+}
+
+// checkReady verifies Bolt connectivity and runs readiness query, if configured.
+func (w *Neo4jWrapper) checkReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if err := w.driver.VerifyConnectivity(ctx); err != nil {
+		return err
+	}
+	r := w.readinessConfig()
+	if r.Query == "" {
+		return nil
+	}
+
+	session := migrator.NewSessionFactory(w.driver, w.cfg.Connection, neo4j.AccessModeRead)(ctx, r.Database)
+	defer session.Close(ctx)
+	result, err := session.Run(ctx, r.Query, nil)
+	if err != nil {
+		return err
+	}
+	if !result.Next(ctx) {
+		if err = result.Err(); err != nil {
+			return err
+		}
+		return errors.New("readiness query returned no rows")
+	}
+	_, err = result.Consume(ctx)
+	return err
+}
+
+// emitReady notifies webhooks and hooks, that Neo4j is ready.
+func (w *Neo4jWrapper) emitReady(startup time.Duration) {
+	w.webhooks.notify(config.EventNeo4jStarted, "Neo4j started in "+startup.Round(time.Second).String(),
+		map[string]any{"startup_seconds": startup.Seconds()})
+	w.callReadinessHooks(nil)
+}
+
+// emitFailure notifies webhooks and hooks, that Neo4j failed. Error is added into data.
+func (w *Neo4jWrapper) emitFailure(text string, err error, data map[string]any) {
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["error"] = err.Error()
+	w.webhooks.notify(config.EventNeo4jFailed, text, data)
+	w.callReadinessHooks(err)
+}
+
+func (w *Neo4jWrapper) callReadinessHooks(err error) {
+	readinessHooksMux.Lock()
+	defer readinessHooksMux.Unlock()
+	for _, hook := range w.readinessHooks {
+		go hook(err)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+// watch waits until neo4j process exits and cleans up the state. When the process was not stopped,
+// the exit is handled as a crash. Channel done is closed after the state is updated.
+func (w *Neo4jWrapper) watch(done chan struct{}) {
+	defer close(done)
+	waitErr := w.process.Wait()
+
+	// Background context, so the state is cleaned up also during shutdown.
+	_ = serviceSem.Acquire(context.Background(), 1)
+	defer serviceSem.Release(1)
+	w.started = false
+	if w.serviceState == Stopping {
+		w.serviceState = Stopped
+		w.log.Trace("Cleaned up after neo4j exited")
+		w.webhooks.notify(config.EventNeo4jStopped, "Neo4j stopped", nil)
+		return
+	}
+	w.crashed(waitErr)
+}
+
+// crashed reports unexpected exit and schedules restart, if allowed by the policy.
+// Must be called with acquired serviceSem.
+func (w *Neo4jWrapper) crashed(waitErr error) {
+	exitCode := w.process.State().ExitCode
+	w.metrics.crashes.Inc()
+	w.cancelBoltChecks()
+	w.log.WithField("exit_code", exitCode).WithField("uptime", time.Since(w.startedAt).Round(time.Second)).
+		Errorf("Neo4j exited unexpectedly: %v", waitErr)
+	w.emitFailure(fmt.Sprintf("Neo4j exited unexpectedly with code %d", exitCode),
+		fmt.Errorf("neo4j exited unexpectedly with code %d", exitCode), map[string]any{"exit_code": exitCode})
+
+	policy := w.restartPolicy()
+	if time.Since(w.startedAt) >= policy.StableAfter {
+		w.restarts = 0
+	}
+	w.serviceState = Failed
+	switch {
+	case policy.Policy == config.RestartNever,
+		policy.Policy == config.RestartOnFailure && waitErr == nil:
+		if waitErr == nil {
+			w.serviceState = Stopped
+		}
+		return
+	case policy.MaxRestarts > 0 && w.restarts >= policy.MaxRestarts:
+		w.log.Errorf("Neo4j crashed %d times in a row, it is not restarted anymore", w.restarts)
+		return
+	}
+
+	delay := policy.InitialBackoff
+	for range w.restarts {
+		delay = min(delay*2, policy.MaxBackoff)
+	}
+	w.restarts++
+	w.serviceState = Restarting
+	w.nextRestartAt = time.Now().Add(delay)
+	w.log.WithField("attempt", w.restarts).WithField("delay", delay).Warn("Neo4j will be restarted")
+	w.restartTimer = time.AfterFunc(delay, w.restartCrashed)
+}
+
+// restartCrashed starts Neo4j after backoff. Unlike Start, it waits for the lock,
+// so concurrent state reads cannot make it fail.
+func (w *Neo4jWrapper) restartCrashed() {
+	if err := serviceSem.Acquire(w.context, 1); err != nil {
+		return
+	}
+	defer serviceSem.Release(1)
+	if w.serviceState != Restarting {
+		// Stopped or started manually in the meantime.
+		return
+	}
+	if err := w.start(); err != nil {
+		w.log.Errorf("Failed to restart service: %v", err)
+		return
+	}
+	w.metrics.restarts.Inc()
+}
+
+// restartInfo returns number of consecutive automatic restarts and time of the scheduled one.
+func (w *Neo4jWrapper) restartInfo() (int, time.Time) {
+	if err := serviceSem.Acquire(w.context, 1); err != nil {
+		return 0, time.Time{}
+	}
+	defer serviceSem.Release(1)
+	return w.restarts, w.nextRestartAt
+}
+
+// restartPolicy returns configured policy. Without supervisor config, Neo4j is never restarted.
+func (w *Neo4jWrapper) restartPolicy() *config.RestartPolicy {
+	if w.cfg.Supervisor == nil || w.cfg.Supervisor.Restart == nil {
+		return &config.RestartPolicy{Policy: config.RestartNever}
+	}
+	return w.cfg.Supervisor.Restart
+}
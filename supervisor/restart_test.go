@@ -0,0 +1,173 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Restart after crash", func() {
+	var (
+		policy  *config.RestartPolicy
+		w       *Neo4jWrapper
+		backend *fakeBackend
+	)
+
+	BeforeEach(func() {
+		// Backoff is long, so restarts are triggered by the test with restartCrashed.
+		policy = &config.RestartPolicy{
+			Policy:         config.RestartOnFailure,
+			InitialBackoff: time.Minute,
+			MaxBackoff:     3 * time.Minute,
+			StableAfter:    time.Hour,
+		}
+	})
+
+	JustBeforeEach(func() {
+		w, backend = newTestWrapper(context.Background(), func(cfg *config.Config) {
+			cfg.Supervisor.Restart = policy
+		})
+		startNeo4j(w)
+		DeferCleanup(func() {
+			_ = w.Stop()
+			Expect(w.Wait()).To(Succeed())
+		})
+	})
+
+	state := func() Neo4jState {
+		s, _ := w.State()
+		return s
+	}
+	// crash exits Neo4j and waits until the watcher handles it.
+	crash := func(exitCode int, expected Neo4jState) {
+		backend.crash(exitCode)
+		Eventually(state).Should(Equal(expected))
+	}
+	// restartDelay returns delay of scheduled restart, rounded to seconds.
+	restartDelay := func() time.Duration {
+		_, at := w.restartInfo()
+		return time.Until(at).Round(time.Second)
+	}
+	counterValue := func(c prometheus.Counter) float64 {
+		m := &dto.Metric{}
+		Expect(c.Write(m)).To(Succeed())
+		return m.GetCounter().GetValue()
+	}
+	restart := func() {
+		prev := boltChecks()
+		w.restartCrashed()
+		Expect(state()).To(Equal(Starting))
+		waitBoltChecks(prev)
+	}
+
+	It("Restarts crashed Neo4j with exponential backoff", func() {
+		crash(137, Restarting)
+		Expect(restartDelay()).To(Equal(time.Minute))
+		Expect(w.AllStates()).To(HaveKeyWithValue("neo4j_restarts", 1))
+		restart()
+		Expect(backend.startCount()).To(Equal(2))
+
+		crash(137, Restarting)
+		Expect(restartDelay()).To(Equal(2 * time.Minute))
+		restart()
+
+		crash(137, Restarting)
+		Expect(restartDelay()).To(Equal(3*time.Minute), "backoff is limited by max_backoff")
+		restarts, _ := w.restartInfo()
+		Expect(restarts).To(Equal(3))
+
+		Expect(counterValue(w.metrics.crashes)).To(Equal(3.0))
+		Expect(counterValue(w.metrics.restarts)).To(Equal(2.0))
+	})
+
+	It("Cancels scheduled restart on stop", func() {
+		crash(1, Restarting)
+		Expect(w.Stop()).To(Succeed())
+		Expect(state()).To(Equal(Stopped))
+		w.restartCrashed()
+		Expect(state()).To(Equal(Stopped))
+		Expect(backend.startCount()).To(Equal(1))
+	})
+
+	It("Does not restart clean exit with on-failure policy", func() {
+		crash(0, Stopped)
+		Expect(backend.startCount()).To(Equal(1))
+		Expect(counterValue(w.metrics.crashes)).To(Equal(1.0))
+	})
+
+	When("max restarts are reached", func() {
+		BeforeEach(func() {
+			policy.MaxRestarts = 1
+		})
+
+		It("Keeps Neo4j failed until it is started again", func() {
+			crash(1, Restarting)
+			restart()
+			crash(1, Failed)
+			restarts, _ := w.restartInfo()
+			Expect(restarts).To(Equal(1))
+
+			startNeo4j(w)
+			restarts, _ = w.restartInfo()
+			Expect(restarts).To(BeZero(), "requested start gives Neo4j new chance")
+			crash(1, Restarting)
+		})
+	})
+
+	When("Neo4j was running long enough", func() {
+		BeforeEach(func() {
+			policy.StableAfter = time.Nanosecond
+		})
+
+		It("Resets the backoff", func() {
+			crash(1, Restarting)
+			restart()
+			crash(1, Restarting)
+			Expect(restartDelay()).To(Equal(time.Minute))
+			restarts, _ := w.restartInfo()
+			Expect(restarts).To(Equal(1))
+		})
+	})
+
+	When("policy is always", func() {
+		BeforeEach(func() {
+			policy.Policy = config.RestartAlways
+		})
+
+		It("Restarts also after clean exit", func() {
+			crash(0, Restarting)
+		})
+	})
+
+	When("policy is never", func() {
+		BeforeEach(func() {
+			policy.Policy = config.RestartNever
+		})
+
+		It("Keeps crashed Neo4j failed", func() {
+			crash(1, Failed)
+			Expect(w.AllStates()).NotTo(HaveKey("neo4j_restarts"))
+		})
+	})
+})
@@ -15,13 +15,20 @@
 package supervisor
 
 import (
+	"context"
 	"errors"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
+	"github.com/indykite/neo4j-graph-tool-core/config"
 	"github.com/indykite/neo4j-graph-tool-core/migrator"
 )
 
@@ -29,6 +36,11 @@ type httpServer struct {
 	neo4j        *Neo4jWrapper
 	log, httpLog logrus.FieldLogger
 	srv          *http.Server
+	jobs         *jobManager
+	logs         *logBroadcaster
+	startedAt    time.Time
+	// closing is closed on shutdown, so log streams end and do not block graceful shutdown.
+	closing chan struct{}
 
 	defaultTargetVersion *migrator.TargetVersion
 	defaultBatch         migrator.Batch
@@ -37,6 +49,7 @@ type httpServer struct {
 func runHTTPServer(
 	neo4j *Neo4jWrapper,
 	logger logrus.FieldLogger,
+	logs *logBroadcaster,
 	targetVersion *migrator.TargetVersion,
 	batch migrator.Batch,
 ) *httpServer {
@@ -44,6 +57,10 @@ func runHTTPServer(
 		neo4j:                neo4j,
 		log:                  logger,
 		httpLog:              logger.WithField(componentLogKey, "http"),
+		jobs:                 newJobManager(neo4j.cfg.Supervisor.JobHistory),
+		logs:                 logs,
+		startedAt:            time.Now(),
+		closing:              make(chan struct{}),
 		defaultTargetVersion: targetVersion,
 		defaultBatch:         batch,
 	}
@@ -52,27 +69,56 @@ func runHTTPServer(
 	gin.SetMode(gin.ReleaseMode)
 	g := gin.New()
 	g.Use(gin.Recovery())
-	g.GET("/refresh-data", s.refreshDataHandler(true))
-	g.GET("/refresh-data/:version", s.refreshDataHandler(true))
-	g.GET("/update-data", s.refreshDataHandler(false))
-	g.GET("/update-data/:version", s.refreshDataHandler(false))
-	g.GET("/version", s.versionHandler)
-	g.GET("/status", s.wrapperStatusHandler)
-	g.GET("/start", s.startServiceHandler)
-	g.GET("/stop", s.stopServiceHandler)
-	g.GET("/restart", s.restartServiceHandler)
+	g.HandleMethodNotAllowed = true
+	// Probes are without auth, so orchestrators can call them without credentials.
+	g.GET("/healthz", s.healthzHandler)
+	g.GET("/readyz", s.readyzHandler)
+	s.registerAPIv1(g)
+	// Read-only routes are safe, others change the state with GET and can be disabled.
+	read := g.Group("", s.authorize(config.RoleRead))
+	read.GET("/version", s.versionHandler)
+	read.GET("/report", s.reportHandler)
+	read.GET("/status", s.wrapperStatusHandler)
+	read.GET("/metrics", gin.WrapH(promhttp.HandlerFor(neo4j.metrics.registry, promhttp.HandlerOpts{})))
+	if neo4j.cfg.Supervisor.LegacyRoutes {
+		logger.Warn("Legacy GET routes changing the state are deprecated, use /api/v1 routes " +
+			"and disable them with supervisor.legacy_routes = false")
+		admin := g.Group("", s.authorize(config.RoleAdmin))
+		admin.GET("/refresh-data", s.refreshDataHandler(true))
+		admin.GET("/refresh-data/:version", s.refreshDataHandler(true))
+		admin.GET("/update-data", s.refreshDataHandler(false))
+		admin.GET("/update-data/:version", s.refreshDataHandler(false))
+		admin.GET("/update-tenants", s.updateTenantsHandler)
+		admin.GET("/update-tenants/:version", s.updateTenantsHandler)
+		admin.GET("/start", s.startServiceHandler)
+		admin.GET("/stop", s.stopServiceHandler)
+		admin.GET("/restart", s.restartServiceHandler)
+	}
 	g.NoRoute(s.error404)
+	g.NoMethod(s.error405)
 
+	cfg := neo4j.cfg.Supervisor
 	s.srv = &http.Server{
-		Addr:              ":8080",
+		Addr:              net.JoinHostPort(cfg.BindAddress, strconv.Itoa(cfg.Port)),
 		Handler:           g,
 		ReadHeaderTimeout: time.Second * 2,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
 	}
+	s.srv.RegisterOnShutdown(func() { close(s.closing) })
 
-	logger.Debug("Starting HTTP server")
+	tls := cfg.TLSCertFile != ""
+	s.httpLog.WithField("addr", s.srv.Addr).WithField("tls", tls).Debug("Starting HTTP server")
 	go func() {
-		// ListenAndServe always returns error. ErrServerClosed on graceful close.
-		if err := s.srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if tls {
+			err = s.srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = s.srv.ListenAndServe()
+		}
+		// Serve always returns error. ErrServerClosed on graceful close.
+		if !errors.Is(err, http.ErrServerClosed) {
 			s.httpLog.Fatalf("Serve failed: %v", err)
 		}
 	}()
@@ -80,12 +126,23 @@ func runHTTPServer(
 	return s
 }
 
-func (s *httpServer) close() error {
-	return s.srv.Close()
+// close stops accepting new requests and waits for running ones, then closes the remaining connections.
+// Zero timeout waits forever.
+func (s *httpServer) close(timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return errors.Join(err, s.srv.Close())
+	}
+	return nil
 }
 
 func (s *httpServer) startServiceHandler(c *gin.Context) {
-	s.httpLog.WithField("req", c.Request.RequestURI).Debug("Dispatching request")
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
 	if err := s.neo4j.Start(); err == nil {
 		state, stateErr := s.neo4j.State()
 		c.JSON(http.StatusOK, gin.H{
@@ -94,13 +151,13 @@ func (s *httpServer) startServiceHandler(c *gin.Context) {
 			"state_err":   stateErr,
 		})
 	} else {
-		s.httpLog.WithField("req", c.Request.RequestURI).Warn(err.Error())
+		s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
 		s.sendError(c, err)
 	}
 }
 
 func (s *httpServer) stopServiceHandler(c *gin.Context) {
-	s.httpLog.WithField("req", c.Request.RequestURI).Debug("Dispatching request")
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
 	if err := s.neo4j.Stop(); err == nil {
 		state, stateErr := s.neo4j.State()
 		c.JSON(http.StatusOK, gin.H{
@@ -109,13 +166,13 @@ func (s *httpServer) stopServiceHandler(c *gin.Context) {
 			"state_err":   stateErr,
 		})
 	} else {
-		s.httpLog.WithField("req", c.Request.RequestURI).Warn(err.Error())
+		s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
 		s.sendError(c, err)
 	}
 }
 
 func (s *httpServer) restartServiceHandler(c *gin.Context) {
-	s.httpLog.WithField("req", c.Request.RequestURI).Debug("Dispatching request")
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
 	if err := s.neo4j.Restart(); err == nil {
 		state, stateErr := s.neo4j.State()
 		c.JSON(http.StatusOK, gin.H{
@@ -124,13 +181,13 @@ func (s *httpServer) restartServiceHandler(c *gin.Context) {
 			"state_err":   stateErr,
 		})
 	} else {
-		s.httpLog.WithField("req", c.Request.RequestURI).Warn(err.Error())
+		s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
 		s.sendError(c, err)
 	}
 }
 
 func (s *httpServer) wrapperStatusHandler(c *gin.Context) {
-	s.httpLog.WithField("req", c.Request.RequestURI).Debug("Dispatching request")
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
 	code := http.StatusServiceUnavailable
 	if state, _ := s.neo4j.State(); state == Running {
 		code = http.StatusOK
@@ -140,7 +197,7 @@ func (s *httpServer) wrapperStatusHandler(c *gin.Context) {
 
 func (s *httpServer) refreshDataHandler(clean bool) func(*gin.Context) {
 	return func(c *gin.Context) {
-		s.httpLog.WithField("req", c.Request.RequestURI).Debug("Dispatching request")
+		s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
 		gs, err := s.parseTargetParams(c)
 		if err != nil {
 			return
@@ -154,23 +211,67 @@ func (s *httpServer) refreshDataHandler(clean bool) func(*gin.Context) {
 		if v, ok := c.GetQuery("batch"); ok {
 			loadBatch = migrator.Batch(v)
 		}
-		if err := s.neo4j.RefreshData(gs, dryRun, clean, loadBatch); err == nil {
+		// Legacy routes do not accept confirmation token, it would leak through URL into logs.
+		// So clean run and rollback of protected batch are always refused.
+		if clean && !dryRun {
+			if err = s.neo4j.checkCleanProtection(loadBatch, ""); err != nil {
+				s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
+				s.sendError(c, err)
+				return
+			}
+		}
+		_, err = s.neo4j.Migrate(MigrateOptions{
+			Target: gs,
+			Batch:  loadBatch,
+			DryRun: dryRun,
+			Clean:  clean,
+		})
+		if err == nil {
 			c.JSON(http.StatusOK, gin.H{
-				"msg": "Data successfully refreshed",
+				"msg":    "Data successfully refreshed",
+				"report": s.neo4j.LastReport(),
 			})
 		} else {
-			s.httpLog.WithField("req", c.Request.RequestURI).Warn(err.Error())
+			s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
 			s.sendError(c, err)
 		}
 	}
 }
 
+func (s *httpServer) updateTenantsHandler(c *gin.Context) {
+	s.httpLog.WithField("req", c.Request.URL.Path).Debug("Dispatching request")
+	gs, err := s.parseTargetParams(c)
+	if err != nil {
+		return
+	}
+	loadBatch := s.defaultBatch
+	if v, ok := c.GetQuery("batch"); ok {
+		loadBatch = migrator.Batch(v)
+	}
+
+	// Confirmation token is not accepted by legacy routes, see refreshDataHandler.
+	report, err := s.neo4j.MigrateTenantsContext(s.neo4j.context, MigrateOptions{
+		Target: gs,
+		Batch:  loadBatch,
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"msg": "Tenants successfully updated", "report": report})
+	case report != nil:
+		s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  err.Error(),
+			"report": report,
+		})
+	default:
+		s.httpLog.WithField("req", c.Request.URL.Path).Warn(err.Error())
+		s.sendError(c, err)
+	}
+}
+
 func (s *httpServer) versionHandler(c *gin.Context) {
-	// config is validated in supervisor
-	p, _ := migrator.NewPlanner(s.neo4j.cfg)
-	session := s.neo4j.ReadOnlySession(c.Request.Context())
-	defer func() { _ = session.Close(c.Request.Context()) }()
-	model, err := p.Version(c.Request.Context(), session)
+	model, err := s.fetchVersion(c)
 	if err != nil {
 		s.sendError(c, err)
 		return
@@ -178,12 +279,51 @@ func (s *httpServer) versionHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, model)
 }
 
-func (*httpServer) error404(c *gin.Context) {
+func (s *httpServer) fetchVersion(c *gin.Context) (migrator.DatabaseModel, error) {
+	// config is validated in supervisor
+	p, _ := migrator.NewPlanner(s.neo4j.cfg)
+	models, err := p.Versions(c.Request.Context(),
+		migrator.NewSessionFactory(s.neo4j.driver, s.neo4j.cfg.Connection, neo4j.AccessModeRead))
+	if err != nil {
+		return nil, err
+	}
+	// Folder names are unique across databases, so response keeps the same format as with single database.
+	model := models.Merge()
+	s.neo4j.metrics.setAppliedVersions(model)
+	return model, nil
+}
+
+func (s *httpServer) reportHandler(c *gin.Context) {
+	report := s.neo4j.LastReport()
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "No migration was executed yet"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (s *httpServer) error404(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, apiV1Prefix) {
+		s.apiAbort(c, http.StatusNotFound, errCodeNotFound, errors.New("not found"))
+		return
+	}
 	c.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Not found"})
 }
 
+func (s *httpServer) error405(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, apiV1Prefix) {
+		s.apiAbort(c, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	c.JSON(http.StatusMethodNotAllowed, gin.H{"status": http.StatusMethodNotAllowed, "error": "Method not allowed"})
+}
+
 func (*httpServer) sendError(c *gin.Context, err error) {
-	c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "error": err.Error()})
+	status := http.StatusInternalServerError
+	if errors.Is(err, migrator.ErrProtected) {
+		status = http.StatusForbidden
+	}
+	c.JSON(status, gin.H{"status": status, "error": err.Error()})
 }
 
 func (s *httpServer) parseTargetParams(c *gin.Context) (*migrator.TargetVersion, error) {
@@ -0,0 +1,43 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Legacy routes", func() {
+	It("Refuse clean run of protected batch even with confirmation token in URL", func() {
+		w, _ := newTestWrapper(context.Background(), func(cfg *config.Config) {
+			cfg.Planner.ConfirmToken = "staging"
+			cfg.Planner.Batches["seed"].Protected = true
+		})
+		s, engine := newTestServer(w)
+		engine.GET("/refresh-data", s.refreshDataHandler(true))
+
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/refresh-data?batch=seed&confirm=staging", nil))
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(MatchJSON(`{"status": 403,
+			"error": "batch is protected, wiping out of batch 'seed' requires confirmation token"}`))
+	})
+})
@@ -0,0 +1,131 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+)
+
+// errShuttingDown is returned, when migration, maintenance or job is requested during shutdown.
+var errShuttingDown = errors.New("supervisor is shutting down")
+
+// interruptMigrations refuses new migrations and maintenance, and interrupts running migrations after their
+// current step.
+func (w *Neo4jWrapper) interruptMigrations() {
+	// Background context, so migrations are interrupted also when the root context is done.
+	_ = serviceSem.Acquire(context.Background(), 1)
+	defer serviceSem.Release(1)
+	if !w.isShuttingDown() {
+		close(w.interrupt)
+	}
+}
+
+func (w *Neo4jWrapper) isShuttingDown() bool {
+	select {
+	case <-w.interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitMigrations waits until running migrations and maintenance finish. Returns false, when timeout was reached
+// first. Zero timeout waits forever.
+func (w *Neo4jWrapper) waitMigrations(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		w.migrations.Wait()
+		close(done)
+	}()
+	return waitTimeout(done, timeout)
+}
+
+// beginMaintenance counts backup or restore as running migration, so shutdown waits for it.
+// Returns errShuttingDown, when shutdown already started. Call migrations.Done when maintenance finished.
+func (w *Neo4jWrapper) beginMaintenance() error {
+	if err := serviceSem.Acquire(w.context, 1); err != nil {
+		return err
+	}
+	defer serviceSem.Release(1)
+	if w.isShuttingDown() {
+		return errShuttingDown
+	}
+	// Shutdown closes interrupt with acquired serviceSem, so nothing is added after it waits.
+	w.migrations.Add(1)
+	return nil
+}
+
+// shutdown interrupts utilities and stops Neo4j with SIGTERM. Neo4j is killed, when it does not exit
+// within the timeout. Zero timeout waits forever.
+func (w *Neo4jWrapper) shutdown(killTimeout time.Duration) error {
+	if err := w.interruptUtilities(); err != nil {
+		w.log.WithError(err).Warn("Cannot interrupt utilities")
+	}
+
+	_ = serviceSem.Acquire(context.Background(), 1)
+	var err error
+	if w.started || w.serviceState == Restarting {
+		err = w.stop(syscall.SIGTERM)
+	}
+	started, done := w.started, w.serviceDone
+	serviceSem.Release(1)
+	if err != nil || !started {
+		return err
+	}
+
+	if waitTimeout(done, killTimeout) {
+		return nil
+	}
+	w.log.WithField("timeout", killTimeout).Warn("Neo4j did not stop in time, killing it")
+	if err = w.process.Signal(os.Kill); err != nil {
+		return err
+	}
+	<-done
+	return nil
+}
+
+// shutdownConfig returns configured shutdown. Without supervisor config, defaults are used.
+func (w *Neo4jWrapper) shutdownConfig() *config.Shutdown {
+	if w.cfg.Supervisor == nil || w.cfg.Supervisor.Shutdown == nil {
+		return &config.Shutdown{
+			MigrationTimeout: config.DefaultMigrationTimeout,
+			KillTimeout:      config.DefaultKillTimeout,
+		}
+	}
+	return w.cfg.Supervisor.Shutdown
+}
+
+// waitTimeout waits until done is closed. Returns false, when timeout was reached first.
+// Zero timeout waits forever.
+func waitTimeout(done <-chan struct{}, timeout time.Duration) bool {
+	if timeout == 0 {
+		<-done
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
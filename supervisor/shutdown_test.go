@@ -0,0 +1,169 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shutdown", func() {
+	var (
+		w       *Neo4jWrapper
+		backend *fakeBackend
+	)
+
+	BeforeEach(func() {
+		w, backend = newTestWrapper(context.Background(), func(cfg *config.Config) {
+			cfg.Supervisor.Restart = &config.RestartPolicy{Policy: config.RestartAlways, InitialBackoff: time.Hour}
+		})
+	})
+
+	state := func() Neo4jState {
+		s, _ := w.State()
+		return s
+	}
+
+	It("Stops Neo4j with SIGTERM", func() {
+		startNeo4j(w)
+		Expect(w.shutdown(time.Minute)).To(Succeed())
+		Expect(backend.receivedSignals()).To(Equal([]os.Signal{syscall.SIGTERM}))
+		Expect(state()).To(Equal(Stopped))
+	})
+
+	It("Kills Neo4j, which ignores SIGTERM", func() {
+		backend.ignoreTerm = true
+		startNeo4j(w)
+		Expect(w.shutdown(10 * time.Millisecond)).To(Succeed())
+		Expect(backend.receivedSignals()).To(Equal([]os.Signal{syscall.SIGTERM, os.Kill}))
+		Expect(state()).To(Equal(Stopped))
+	})
+
+	It("Cancels scheduled restart", func() {
+		startNeo4j(w)
+		backend.crash(1)
+		Eventually(state).Should(Equal(Restarting))
+		Expect(w.shutdown(time.Minute)).To(Succeed())
+		Expect(state()).To(Equal(Stopped))
+		Expect(backend.receivedSignals()).To(BeEmpty())
+	})
+
+	It("Waits for running maintenance and refuses new one", func() {
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- w.maintenance(context.Background(), "restore", func() error {
+				<-release
+				return nil
+			})
+		}()
+		Eventually(state).Should(Equal(Maintenance))
+
+		w.interruptMigrations()
+		Expect(w.waitMigrations(10 * time.Millisecond)).To(BeFalse())
+		close(release)
+		Expect(w.waitMigrations(time.Minute)).To(BeTrue())
+		Expect(<-done).To(Succeed())
+		Expect(state()).To(Equal(Stopped))
+
+		err := w.maintenance(context.Background(), "backup", func() error {
+			Fail("maintenance must not run during shutdown")
+			return nil
+		})
+		Expect(err).To(MatchError(errShuttingDown))
+	})
+
+	It("Does not start Neo4j again after maintenance during shutdown", func() {
+		startNeo4j(w)
+		// Pretend Bolt is ready, only running Neo4j is started again after maintenance.
+		_ = serviceSem.Acquire(context.Background(), 1)
+		w.serviceState = Running
+		serviceSem.Release(1)
+
+		Expect(w.maintenance(context.Background(), "backup", func() error {
+			w.interruptMigrations()
+			return nil
+		})).To(Succeed())
+		Expect(state()).To(Equal(Stopped))
+		Expect(backend.startCount()).To(Equal(1))
+	})
+
+	It("Stores report of interrupted migration", func() {
+		w.cfg.Planner.ReportFile = filepath.Join(GinkgoT().TempDir(), "report.json")
+		w.storeReport(&migrator.ExecutionReport{Error: "execution interrupted; context canceled", Interrupted: true})
+
+		data, err := os.ReadFile(w.cfg.Planner.ReportFile)
+		Expect(err).To(Succeed())
+		report := migrator.ExecutionReport{}
+		Expect(json.Unmarshal(data, &report)).To(Succeed())
+		Expect(report.Interrupted).To(BeTrue())
+		Expect(report.Error).To(Equal("execution interrupted; context canceled"))
+		Expect(w.LastReport().Interrupted).To(BeTrue())
+	})
+
+	It("Ends log streams and closes HTTP server gracefully", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(Succeed())
+		port := l.Addr().(*net.TCPAddr).Port
+		Expect(l.Close()).To(Succeed())
+		w.cfg.Supervisor.BindAddress, w.cfg.Supervisor.Port = "127.0.0.1", port
+		s := runHTTPServer(w, testLogger(), newLogBroadcaster(10), nil, "")
+
+		get := func() (*http.Response, error) {
+			url := "http://127.0.0.1:" + strconv.Itoa(port) + "/api/v1/logs/stream"
+			req, reqErr := http.NewRequestWithContext(context.Background(), http.MethodGet, url, http.NoBody)
+			Expect(reqErr).To(Succeed())
+			return http.DefaultClient.Do(req)
+		}
+		var resp *http.Response
+		Eventually(func() (err error) {
+			resp, err = get()
+			return err
+		}).Should(Succeed())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		started := time.Now()
+		Expect(s.close(time.Minute)).To(Succeed())
+		Expect(time.Since(started)).To(BeNumerically("<", 10*time.Second))
+
+		// Stream ends, so reading reaches EOF.
+		r := bufio.NewReader(resp.Body)
+		for {
+			if _, err = r.ReadString('\n'); err != nil {
+				break
+			}
+		}
+		closedResp, err := get()
+		if closedResp != nil {
+			_ = closedResp.Body.Close()
+		}
+		Expect(err).To(HaveOccurred(), "closed server does not accept new requests")
+	})
+})
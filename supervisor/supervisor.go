@@ -29,6 +29,9 @@ import (
 	"github.com/indykite/neo4j-graph-tool-core/migrator"
 )
 
+// tracingShutdownTimeout limits how long supervisor waits for flushing traces on exit.
+const tracingShutdownTimeout = 5 * time.Second
+
 type supervisor struct {
 	context   context.Context //nolint:containedctx // Context here is expected and required, it is root context
 	cancelCtx context.CancelFunc
@@ -50,6 +53,10 @@ func Start(cfg *config.Config) error {
 	if err = cfg.ValidateWithSupervisor(); err != nil {
 		return err
 	}
+	// Config might be created manually, so make sure Connection is set.
+	if err = cfg.Normalize(); err != nil {
+		return err
+	}
 	// Program is checking interrupt channel. But even if it wouldn't, signal.Notify must be here.
 	// Otherwise, the program nor the sub proccesses will not receive interrupt signal
 	// and docker will kill it immediately
@@ -59,12 +66,27 @@ func Start(cfg *config.Config) error {
 	log := logrus.New()
 	log.SetLevel(stringToLogrusLogLevel(cfg.Supervisor.LogLevel))
 	log.Formatter = &nested.Formatter{FieldsOrder: []string{componentLogKey}}
+	logs := newLogBroadcaster(cfg.Supervisor.LogBuffer)
+	log.AddHook(logs)
 
 	log.Info("Starting supervisor")
 
 	ctx, cancelCtx := context.WithCancel(context.Background())
 	defer cancelCtx()
 
+	shutdownTracing, err := migrator.StartTracing(ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("cannot start tracing: %w", err)
+	}
+	defer func() {
+		// Root context is canceled already, but spans must be flushed.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.WithError(err).Warn("Cannot flush traces")
+		}
+	}()
+
 	var neo4j *Neo4jWrapper
 	neo4j, err = NewNeo4jWrapper(ctx, cfg, log.WithField(componentLogKey, "wrapper"))
 	if err != nil {
@@ -84,7 +106,7 @@ func Start(cfg *config.Config) error {
 	}
 
 	// Start HTTP server in background thread
-	s.httpServer = runHTTPServer(neo4j, log, s.defaultGraphVersion, s.initialBatch)
+	s.httpServer = runHTTPServer(neo4j, log, logs, s.defaultGraphVersion, s.initialBatch)
 
 	// Always start Neo4j when supervisor is started
 	err = neo4j.Start()
@@ -145,22 +167,43 @@ func (s *supervisor) bootstrapDB() {
 	}
 }
 
+// stop refuses new jobs and migrations, waits for the running migration step and then stops Neo4j.
+// Root context is canceled, when migration does not finish in time, so the step is killed.
 func (s *supervisor) stop() {
 	s.log.Debug("Interrupt signal received - Stopping all")
+	shutdown := s.neo4j.shutdownConfig()
+
+	s.httpServer.jobs.close()
+	s.neo4j.interruptMigrations()
+	start := time.Now()
+	if !s.neo4j.waitMigrations(shutdown.MigrationTimeout) {
+		s.log.WithField("timeout", shutdown.MigrationTimeout).Warn("Migration did not finish in time, killing it")
+	}
+	// Running requests finish before the root context is canceled, within the same timeout as migrations.
+	httpTimeout := shutdown.MigrationTimeout
+	if httpTimeout > 0 {
+		httpTimeout = max(httpTimeout-time.Since(start), time.Nanosecond)
+	}
+	if err := s.httpServer.close(httpTimeout); err != nil {
+		s.log.WithError(err).Warn("HTTP server did not stop gracefully")
+	}
 	s.cancelCtx()
+	// Killed migration still stores its interrupted report.
+	if !s.neo4j.waitMigrations(shutdown.KillTimeout) {
+		s.log.WithField("timeout", shutdown.KillTimeout).Warn("Killed migration did not finish in time")
+	}
 
-	// When closing server, we don't really care about error
-	_ = s.httpServer.close()
 	var err error
 	if s.neo4j.driver != nil {
-		err = s.neo4j.driver.Close(s.context)
+		// Root context is canceled already, but the driver must be closed.
+		err = s.neo4j.driver.Close(context.Background())
 		if err != nil {
 			s.log.Error(err)
 		}
 	}
 
-	// Send stop all to neo4j first, takes the longest time
-	err = s.neo4j.StopAll()
+	// Stopping Neo4j takes the longest time, utilities are interrupted together with it.
+	err = s.neo4j.shutdown(shutdown.KillTimeout)
 	if err != nil {
 		s.log.Error(err)
 	}
@@ -169,8 +212,6 @@ func (s *supervisor) stop() {
 	if err != nil {
 		s.log.Error(err)
 	}
-
-	// Give time for goroutines to finnish properly
-	time.Sleep(1 * time.Millisecond)
+	s.neo4j.webhooks.close(webhookShutdownTimeout)
 	s.log.Info("All quit, good bye")
 }
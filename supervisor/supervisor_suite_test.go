@@ -0,0 +1,191 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSupervisor(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Supervisor Suite")
+}
+
+// testLogger returns logger, which discards everything.
+func testLogger() *logrus.Entry {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return logrus.NewEntry(l)
+}
+
+// fakeBackend is ProcessBackend, which only pretends running Neo4j. Process exits on signal, unless ignoreTerm
+// is set, or on crash.
+type fakeBackend struct {
+	mu         sync.Mutex
+	starts     int
+	signals    []os.Signal
+	ignoreTerm bool
+	exitCode   int
+	running    bool
+	done       chan struct{}
+	err        error
+}
+
+func (b *fakeBackend) Start(*logrus.Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.starts++
+	b.running = true
+	b.done = make(chan struct{})
+	b.err = nil
+	return nil
+}
+
+func (b *fakeBackend) Signal(sig os.Signal) error {
+	b.mu.Lock()
+	b.signals = append(b.signals, sig)
+	ignore := b.ignoreTerm && sig != os.Kill
+	b.mu.Unlock()
+	if !ignore {
+		b.exit(nil, 0)
+	}
+	return nil
+}
+
+func (b *fakeBackend) Wait() error {
+	b.mu.Lock()
+	done := b.done
+	b.mu.Unlock()
+	<-done
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *fakeBackend) State() ProcessState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return ProcessState{PID: 42, Running: b.running, ExitCode: b.exitCode}
+}
+
+// crash exits the running process with given exit code, zero code exits cleanly.
+func (b *fakeBackend) crash(exitCode int) {
+	var err error
+	if exitCode != 0 {
+		err = errors.New("exit status " + strconv.Itoa(exitCode))
+	}
+	b.exit(err, exitCode)
+}
+
+func (b *fakeBackend) exit(err error, exitCode int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.running {
+		return
+	}
+	b.running, b.err, b.exitCode = false, err, exitCode
+	close(b.done)
+}
+
+func (b *fakeBackend) startCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.starts
+}
+
+func (b *fakeBackend) receivedSignals() []os.Signal {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]os.Signal{}, b.signals...)
+}
+
+// newTestWrapper returns wrapper with default config and fake backend. Bolt is never checked, so started Neo4j
+// stays Starting. Batch 'seed' stores data folder into database 'audit', schema is in the connection database
+// 'neo4j'. Backups are stored in temporary directory. Configure can change the config before the wrapper is created.
+func newTestWrapper(ctx context.Context, configure func(cfg *config.Config)) (*Neo4jWrapper, *fakeBackend) {
+	cfg, err := config.New()
+	Expect(err).To(Succeed())
+	cfg.Connection.Database = "neo4j"
+	cfg.Planner.BaseFolder = GinkgoT().TempDir()
+	cfg.Planner.Folders = map[string]*config.FolderDetail{"data": {}}
+	cfg.Planner.Batches = map[string]*config.BatchDetail{"seed": {Folders: []string{"data"}, Database: "audit"}}
+	cfg.Supervisor.Readiness = &config.Readiness{Interval: time.Hour}
+	cfg.Supervisor.Backup.Dir = GinkgoT().TempDir()
+	cfg.Supervisor.Backup.Neo4jAdmin = "true"
+	if configure != nil {
+		configure(cfg)
+	}
+	Expect(cfg.Normalize()).To(Succeed())
+
+	w, err := NewNeo4jWrapper(ctx, cfg, testLogger())
+	Expect(err).To(Succeed())
+	backend := &fakeBackend{}
+	w.WithProcessBackend(backend)
+	return w, backend
+}
+
+// startNeo4j starts Neo4j and waits until Bolt checks can be canceled, so crash and stop do not race with them.
+func startNeo4j(w *Neo4jWrapper) {
+	prev := boltChecks()
+	Expect(w.Start()).To(Succeed())
+	waitBoltChecks(prev)
+}
+
+// boltChecks returns channel canceling running Bolt checks, nil when they are not running.
+func boltChecks() chan os.Signal {
+	_ = serviceSem.Acquire(context.Background(), 1)
+	defer serviceSem.Release(1)
+	return cancelWaitingChan
+}
+
+// waitBoltChecks waits until Bolt checks of newly started Neo4j replace the previous ones.
+func waitBoltChecks(prev chan os.Signal) {
+	Eventually(func() bool {
+		c := boltChecks()
+		return c != nil && c != prev
+	}).Should(BeTrue())
+}
+
+// newTestServer returns API routes of the wrapper, which can be served without listening.
+func newTestServer(w *Neo4jWrapper) (*httpServer, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	s := &httpServer{
+		neo4j:        w,
+		log:          testLogger(),
+		httpLog:      testLogger(),
+		jobs:         newJobManager(10),
+		logs:         newLogBroadcaster(10),
+		closing:      make(chan struct{}),
+		defaultBatch: config.DefaultInitialBatch,
+	}
+	g := gin.New()
+	s.registerAPIv1(g)
+	return s, g
+}
@@ -24,6 +24,8 @@ import (
 	"sync"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
 )
 
 var removeLogTimeRegex = regexp.MustCompile(
@@ -38,7 +40,8 @@ type errorWrap struct {
 type TSCmd struct {
 	errWrap *errorWrap
 	*exec.Cmd
-	mu sync.Mutex
+	mu      sync.Mutex
+	readers sync.WaitGroup
 }
 
 // WaitTS (Wait Thread Safe) will wait until command stops. This can be called multiple times with same result.
@@ -49,6 +52,8 @@ func (c *TSCmd) WaitTS() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.errWrap == nil {
+		// Wait closes stdout and stderr pipes, so all output must be read before.
+		c.readers.Wait()
 		c.errWrap = &errorWrap{
 			err: c.Wait(),
 		}
@@ -61,14 +66,30 @@ func (c *TSCmd) WaitTS() error {
 // Also stdout and stderr are redirected into log.
 // Argument stdin is redirected into the command, if is set.
 func StartCmd(log *logrus.Entry, stdin io.Reader, args ...string) (cmd *TSCmd, err error) {
-	log.Debug("Executing: ", args)
+	return StartCmdWithEnv(log, stdin, nil, args...)
+}
+
+// StartCmdWithEnv works as StartCmd, but also adds env variables in form KEY=value
+// on top of the environment of the current process.
+func StartCmdWithEnv(log *logrus.Entry, stdin io.Reader, env []string, args ...string) (cmd *TSCmd, err error) {
+	return startCmd(context.Background(), log, &migrator.Process{Args: args, Env: env, Stdin: stdin})
+}
+
+// startCmd starts the command and redirects its output into log.
+// When Stdout or Stderr of the command is set, output is copied there as well line by line.
+// The process is killed, when ctx is done before the command finishes.
+func startCmd(ctx context.Context, log *logrus.Entry, c *migrator.Process) (cmd *TSCmd, err error) {
+	log.Debug("Executing: ", c.Args)
 	cmd = &TSCmd{
 		// #nosec G204
-		Cmd: exec.CommandContext(context.Background(), args[0], args[1:]...),
+		Cmd: exec.CommandContext(ctx, c.Args[0], c.Args[1:]...),
+	}
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
 	}
 	cmd.Stdin = os.Stdin
-	if stdin != nil {
-		cmd.Stdin = stdin
+	if c.Stdin != nil {
+		cmd.Stdin = c.Stdin
 	}
 	outPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -87,23 +108,23 @@ func StartCmd(log *logrus.Entry, stdin io.Reader, args ...string) (cmd *TSCmd, e
 		return nil, err
 	}
 
-	// Scanning the StdOut  pipe until is closed
-	go func() {
-		log.Trace("Listening for StdOut")
-		s := bufio.NewScanner(outPipe)
-		for s.Scan() {
-			log.Info(removeLogTimeRegex.ReplaceAllString(s.Text(), "> "))
-		}
-		log.Trace("Scanner of StdOut stopped")
-	}()
-	go func() {
-		log.Trace("Listening for StdErr")
-		s := bufio.NewScanner(errPipe)
-		for s.Scan() {
-			log.Warn(removeLogTimeRegex.ReplaceAllString(s.Text(), "> "))
-		}
-		log.Trace("Scanner of StdErr stopped")
-	}()
+	// Scanning the StdOut and StdErr pipes until are closed
+	cmd.readers.Add(2)
+	go scanOutput(&cmd.readers, log, outPipe, c.Stdout, logrus.InfoLevel)
+	go scanOutput(&cmd.readers, log, errPipe, c.Stderr, logrus.WarnLevel)
 
 	return cmd, nil
 }
+
+func scanOutput(wg *sync.WaitGroup, log *logrus.Entry, pipe io.Reader, copyTo io.Writer, level logrus.Level) {
+	defer wg.Done()
+	log.Trace("Listening for output")
+	s := bufio.NewScanner(pipe)
+	for s.Scan() {
+		log.Log(level, removeLogTimeRegex.ReplaceAllString(s.Text(), "> "))
+		if copyTo != nil {
+			_, _ = io.WriteString(copyTo, s.Text()+"\n")
+		}
+	}
+	log.Trace("Scanner of output stopped")
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+	"github.com/indykite/neo4j-graph-tool-core/migrator"
+)
+
+const (
+	webhookInitialBackoff = time.Second
+	webhookMaxBackoff     = time.Minute
+	// webhookShutdownTimeout limits how long pending deliveries can delay the exit.
+	webhookShutdownTimeout = 10 * time.Second
+
+	webhookEventHeader     = "X-Graph-Tool-Event"
+	webhookDeliveryHeader  = "X-Graph-Tool-Delivery"
+	webhookSignatureHeader = "X-Graph-Tool-Signature"
+)
+
+type (
+	// webhookEvent is JSON body sent to webhooks. Text is human-readable summary,
+	// so chat incoming webhooks can display the event without any transformation.
+	webhookEvent struct {
+		ID    string    `json:"id"`
+		Event string    `json:"event"`
+		Time  time.Time `json:"time"`
+		Text  string    `json:"text"`
+		Data  any       `json:"data,omitempty"`
+	}
+
+	// migrationSummary is data of migration events. Duration and Error are set when the migration is done.
+	migrationSummary struct {
+		Batch    string   `json:"batch"`
+		Target   string   `json:"target_version,omitempty"`
+		Clean    bool     `json:"clean"`
+		Steps    int      `json:"steps"`
+		Files    []string `json:"files"`
+		Duration string   `json:"duration,omitempty"`
+		Error    string   `json:"error,omitempty"`
+	}
+
+	// webhookNotifier delivers events to all webhooks in background. Deliveries have own context,
+	// so events sent during shutdown are still delivered, see close.
+	webhookNotifier struct {
+		hooks  []*config.Webhook
+		client *http.Client
+		log    logrus.FieldLogger
+		// initialBackoff is delay before the first retry, doubled for every next one.
+		initialBackoff time.Duration
+
+		ctx    context.Context //nolint:containedctx // Context of all deliveries, canceled by close.
+		cancel context.CancelFunc
+		mu     sync.Mutex
+		closed bool
+		wg     sync.WaitGroup
+	}
+)
+
+func newWebhookNotifier(hooks []*config.Webhook, log logrus.FieldLogger) *webhookNotifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webhookNotifier{
+		hooks:          hooks,
+		client:         &http.Client{},
+		log:            log,
+		initialBackoff: webhookInitialBackoff,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// newMigrationSummary describes planned steps. Files are listed once, even when they produced more steps.
+func newMigrationSummary(opts MigrateOptions, steps migrator.ExecutionSteps) *migrationSummary {
+	summary := &migrationSummary{
+		Batch: string(opts.Batch),
+		Clean: opts.Clean,
+		Steps: len(steps),
+		Files: []string{},
+	}
+	if opts.Target != nil {
+		summary.Target = opts.Target.String()
+	}
+	for _, step := range steps {
+		if mc := step.Migration(); mc != nil && !slices.Contains(summary.Files, mc.Path) {
+			summary.Files = append(summary.Files, mc.Path)
+		}
+	}
+	return summary
+}
+
+// notify sends the event to all webhooks subscribed to it. It never blocks.
+func (n *webhookNotifier) notify(event, text string, data any) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+
+	ev := webhookEvent{ID: newJobID(), Event: event, Time: time.Now(), Text: text, Data: data}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		n.log.WithError(err).Error("Cannot encode webhook event")
+		return
+	}
+	for _, hook := range n.hooks {
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, event) {
+			continue
+		}
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.deliver(hook, ev, body)
+		}()
+	}
+}
+
+// deliver posts the event until it is accepted. Client errors are not retried, except 408 and 429.
+func (n *webhookNotifier) deliver(hook *config.Webhook, ev webhookEvent, body []byte) {
+	log := n.log.WithField("url", hook.URL).WithField("event", ev.Event)
+	backoff := n.initialBackoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := n.post(hook, ev, body)
+		if err == nil {
+			log.WithField("attempt", attempt).Debug("Webhook delivered")
+			return
+		}
+		if !retryable || attempt >= hook.MaxAttempts {
+			log.WithField("attempt", attempt).Warnf("Webhook delivery failed: %v", err)
+			return
+		}
+		log.WithField("attempt", attempt).WithField("delay", backoff).
+			Debugf("Webhook delivery failed, will retry: %v", err)
+		select {
+		case <-n.ctx.Done():
+			log.Warn("Webhook delivery canceled")
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, webhookMaxBackoff)
+	}
+}
+
+// post sends single attempt and returns if failed delivery can be retried.
+func (n *webhookNotifier) post(hook *config.Webhook, ev webhookEvent, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, hook.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, ev.Event)
+	req.Header.Set(webhookDeliveryHeader, ev.ID)
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		_, _ = mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	// Drain the body, so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// close stops accepting new events and waits for pending deliveries, at most for the timeout.
+func (n *webhookNotifier) close(timeout time.Duration) {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		n.log.Warn("Pending webhook deliveries canceled")
+	}
+	n.cancel()
+}
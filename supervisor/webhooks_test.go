@@ -0,0 +1,135 @@
+// Copyright (c) 2023 IndyKite
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/indykite/neo4j-graph-tool-core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Webhooks", func() {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+	var (
+		mu         sync.Mutex
+		deliveries []delivery
+		statuses   []int
+		srv        *httptest.Server
+		hook       *config.Webhook
+	)
+
+	BeforeEach(func() {
+		deliveries, statuses = nil, nil
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			status := http.StatusOK
+			if i := len(deliveries); i < len(statuses) {
+				status = statuses[i]
+			}
+			deliveries = append(deliveries, delivery{header: r.Header, body: body})
+			w.WriteHeader(status)
+		}))
+		DeferCleanup(srv.Close)
+		hook = &config.Webhook{URL: srv.URL, MaxAttempts: 5, Timeout: time.Second}
+	})
+
+	// send notifies the event and waits until all deliveries are done.
+	send := func(events ...string) {
+		n := newWebhookNotifier([]*config.Webhook{hook}, testLogger())
+		n.initialBackoff = time.Millisecond
+		for _, event := range events {
+			n.notify(event, "Migration of batch 'schema'", &migrationSummary{Batch: "schema", Files: []string{}})
+		}
+		n.close(5 * time.Second)
+	}
+
+	It("Signs the body with secret", func() {
+		hook.Secret = "top-secret"
+		send(config.EventMigrationStarted)
+
+		Expect(deliveries).To(HaveLen(1))
+		mac := hmac.New(sha256.New, []byte("top-secret"))
+		_, _ = mac.Write(deliveries[0].body)
+		Expect(deliveries[0].header.Get("X-Graph-Tool-Signature")).To(
+			Equal("sha256=" + hex.EncodeToString(mac.Sum(nil))))
+		Expect(deliveries[0].header.Get("X-Graph-Tool-Event")).To(Equal(config.EventMigrationStarted))
+		Expect(deliveries[0].header.Get("Content-Type")).To(Equal("application/json"))
+
+		ev := webhookEvent{}
+		Expect(json.Unmarshal(deliveries[0].body, &ev)).To(Succeed())
+		Expect(ev.ID).To(Equal(deliveries[0].header.Get("X-Graph-Tool-Delivery")))
+		Expect(ev.Event).To(Equal(config.EventMigrationStarted))
+		Expect(ev.Text).To(Equal("Migration of batch 'schema'"))
+	})
+
+	It("Does not sign the body without secret", func() {
+		send(config.EventMigrationStarted)
+		Expect(deliveries).To(HaveLen(1))
+		Expect(deliveries[0].header).NotTo(HaveKey("X-Graph-Tool-Signature"))
+	})
+
+	It("Retries server errors, timeout and too many requests", func() {
+		statuses = []int{http.StatusBadGateway, http.StatusRequestTimeout, http.StatusTooManyRequests}
+		send(config.EventMigrationFinished)
+		Expect(deliveries).To(HaveLen(4))
+		for _, d := range deliveries[1:] {
+			Expect(d.body).To(Equal(deliveries[0].body))
+		}
+	})
+
+	It("Does not retry other client errors", func() {
+		statuses = []int{http.StatusBadRequest, http.StatusBadRequest}
+		send(config.EventMigrationFinished)
+		Expect(deliveries).To(HaveLen(1))
+	})
+
+	It("Gives up after max attempts", func() {
+		hook.MaxAttempts = 3
+		statuses = slices.Repeat([]int{http.StatusServiceUnavailable}, 5)
+		send(config.EventMigrationFailed)
+		Expect(deliveries).To(HaveLen(3))
+	})
+
+	It("Sends only subscribed events", func() {
+		hook.Events = []string{config.EventMigrationFailed}
+		send(config.EventMigrationStarted, config.EventMigrationFailed, config.EventMigrationFinished)
+		Expect(deliveries).To(HaveLen(1))
+		Expect(deliveries[0].header.Get("X-Graph-Tool-Event")).To(Equal(config.EventMigrationFailed))
+	})
+
+	It("Drops events after close", func() {
+		n := newWebhookNotifier([]*config.Webhook{hook}, testLogger())
+		n.close(time.Second)
+		n.notify(config.EventMigrationStarted, "Migration started", nil)
+		Expect(deliveries).To(BeEmpty())
+	})
+})
@@ -16,19 +16,20 @@ package supervisor
 
 import (
 	"context"
-	"errors"
 	"path/filepath"
 	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
 	"github.com/sirupsen/logrus"
 
+	"github.com/indykite/neo4j-graph-tool-core/config"
 	"github.com/indykite/neo4j-graph-tool-core/migrator"
 )
 
 // ReadOnlySession returns new Neo4j session for custom Cypher calls.
 func (w *Neo4jWrapper) ReadOnlySession(ctx context.Context) neo4j.Session {
-	return w.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	return migrator.NewSession(ctx, w.driver, w.cfg.Connection, neo4j.AccessModeRead)
 }
 
 func (w *Neo4jWrapper) getImportDir() string {
@@ -36,7 +37,11 @@ func (w *Neo4jWrapper) getImportDir() string {
 	if strings.HasPrefix(w.cfg.Planner.BaseFolder, "/") {
 		path = w.cfg.Planner.BaseFolder
 	} else {
-		path = initialDataDir + w.cfg.Planner.BaseFolder
+		dataDir := config.DefaultInitialDataDir
+		if w.cfg.Supervisor != nil && w.cfg.Supervisor.InitialDataDir != "" {
+			dataDir = w.cfg.Supervisor.InitialDataDir
+		}
+		path = filepath.Join(dataDir, w.cfg.Planner.BaseFolder)
 	}
 
 	if !strings.HasSuffix(path, "/") {
@@ -45,34 +50,120 @@ func (w *Neo4jWrapper) getImportDir() string {
 	return path
 }
 
-// getNeo4jBasicAuth returns username, password and realm just like neo4j.BasicAuth() requires.
-//
-//nolint:unparam // Is prepared fo future use.
-func (w *Neo4jWrapper) getNeo4jBasicAuth() (username, password, realm string) {
-	auth := strings.SplitN(w.cfg.Supervisor.Neo4jAuth, "/", 2)
-	if len(auth) == 2 {
-		return auth[0], auth[1], ""
+// getReportPath returns path of the execution report. Relative path is resolved next to the import dir.
+func (w *Neo4jWrapper) getReportPath() string {
+	if filepath.IsAbs(w.cfg.Planner.ReportFile) {
+		return w.cfg.Planner.ReportFile
 	}
+	return filepath.Join(filepath.Dir(filepath.Clean(w.getImportDir())), w.cfg.Planner.ReportFile)
+}
 
-	return "", "", ""
+func (w *Neo4jWrapper) drop(
+	ctx context.Context,
+	p *migrator.Planner,
+	steps *migrator.ExecutionSteps,
+	batch migrator.Batch,
+) error {
+	w.log.WithField("strategy", w.cfg.Planner.WipeStrategy).Trace("Adding wipe out to execution steps")
+	return p.AddWipe(ctx, steps, w.getImportDir(), batch,
+		migrator.NewSessionFactory(w.driver, w.cfg.Connection, neo4j.AccessModeRead))
 }
 
-func (w *Neo4jWrapper) drop(steps *migrator.ExecutionSteps) error {
-	if w.cfg.Planner.DropCypherFile == "" {
-		return errors.New("drop cypher file is not specified")
+// checkCleanProtection refuses clean migration of protected batch without valid confirmation token.
+func (w *Neo4jWrapper) checkCleanProtection(batch migrator.Batch, confirm string) error {
+	p, err := migrator.NewPlanner(w.cfg)
+	if err != nil {
+		return err
 	}
-	fp, err := filepath.Abs(w.getImportDir() + w.cfg.Planner.DropCypherFile)
+	return p.CheckProtection(batch, true, nil, confirm)
+}
+
+// checkRestoreProtection refuses restore of protected database without valid confirmation token.
+func (w *Neo4jWrapper) checkRestoreProtection(database, confirm string) error {
+	p, err := migrator.NewPlanner(w.cfg)
 	if err != nil {
 		return err
 	}
-	w.log.WithField("file", fp).Trace("Adding drop file to execution steps")
-	steps.AddCypher(
-		"// wipe out the entire database\n",
-		":source ", fp, "\n",
-	)
-	return nil
+	return p.CheckRestoreProtection(database, confirm)
+}
+
+// configWithRetry returns config with retry policy replaced, or the original config if retry is nil.
+func (w *Neo4jWrapper) configWithRetry(retry *config.RetryPolicy) *config.Config {
+	if retry == nil {
+		return w.cfg
+	}
+	cfg := *w.cfg
+	planner := *cfg.Planner
+	planner.Retry = retry
+	cfg.Planner = &planner
+	return &cfg
+}
+
+// createDatabases creates missing databases of the batch, when enabled in config.
+func (w *Neo4jWrapper) createDatabases(ctx context.Context, p *migrator.Planner, batch migrator.Batch) error {
+	if !w.cfg.Connection.CreateDatabase {
+		return nil
+	}
+	created, err := p.CreateDatabases(ctx,
+		migrator.NewSessionFactory(w.driver, w.cfg.Connection, neo4j.AccessModeWrite), batch)
+	for _, db := range created {
+		w.log.WithField("database", db).Info("Database created")
+	}
+	return err
 }
 
 func (w *Neo4jWrapper) utilsLog(utilName string) *logrus.Entry {
 	return w.log.WithField(componentLogKey, utilName)
 }
+
+// envValue returns value of the variable from env in form KEY=value. The later value wins, as in exec.Cmd.
+func envValue(env []string, key string) string {
+	value := ""
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			value = v
+		}
+	}
+	return value
+}
+
+// refreshVersionMetrics fetches applied versions after migration. Failure is only logged, migration is done already.
+func (w *Neo4jWrapper) refreshVersionMetrics(ctx context.Context, p *migrator.Planner) {
+	models, err := p.Versions(ctx, migrator.NewSessionFactory(w.driver, w.cfg.Connection, neo4j.AccessModeRead))
+	if err != nil {
+		w.log.WithError(err).Debug("Cannot fetch versions for metrics")
+		return
+	}
+	w.metrics.setAppliedVersions(models.Merge())
+}
+
+// pendingFiles returns number of migration files of the batch, which are not applied to the database yet.
+// Files are only counted by the builder and not read.
+func (w *Neo4jWrapper) pendingFiles(
+	ctx context.Context,
+	target *migrator.TargetVersion,
+	batch migrator.Batch,
+) (int, error) {
+	p, err := migrator.NewPlanner(w.cfg)
+	if err != nil {
+		return 0, err
+	}
+	models, err := p.Versions(ctx, migrator.NewSessionFactory(w.driver, w.cfg.Connection, neo4j.AccessModeRead))
+	if err != nil {
+		return 0, err
+	}
+	scanner, err := p.NewScanner(w.getImportDir())
+	if err != nil {
+		return 0, err
+	}
+	lf, err := scanner.ScanFoldersContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = p.PlanContext(ctx, lf, models.Merge(), target, batch, func(*migrator.MigrationFile, *semver.Version) error {
+		count++
+		return nil
+	})
+	return count, err
+}